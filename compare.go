@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+// significantChange is the percentage of change highlighted by the compare view
+const significantChange = 5
+
+// Comparison aligns the steps of several stored results by their load. The
+// first result is the baseline of the deltas.
+type Comparison struct {
+	IDs []string
+	// Unit is "C" for the concurrency mode or "rps" for the rate one
+	Unit  string
+	Steps []ComparedStep
+}
+
+// ComparedStep holds the metrics of every result for the same load. A result
+// without a step with that load has a nil entry.
+type ComparedStep struct {
+	Load    int
+	Results []*StepMetrics
+}
+
+// StepMetrics summarizes a step. Latencies are in seconds.
+type StepMetrics struct {
+	Rps        float64
+	Average    float64
+	P50        float64
+	P90        float64
+	P99        float64
+	Errors     int
+	ErrorRatio float64
+	// Deltas compares the step with the one of the baseline
+	Deltas *StepDeltas `json:",omitempty"`
+}
+
+type StepDeltas struct {
+	Rps        Delta
+	Average    Delta
+	P50        Delta
+	P90        Delta
+	P99        Delta
+	ErrorRatio Delta
+}
+
+// Delta is the difference with the baseline. Change is a percentage of the
+// value of the baseline, so it is not set when the baseline is 0.
+type Delta struct {
+	Diff   float64
+	Change float64 `json:",omitempty"`
+}
+
+func newDelta(value, base float64) Delta {
+	d := Delta{Diff: value - base}
+	if base != 0 {
+		d.Change = 100 * d.Diff / base
+	}
+	return d
+}
+
+// String returns the change, or the difference if the baseline is 0
+func (d Delta) String() string {
+	if d.Change != 0 {
+		return fmt.Sprintf("%+.1f%%", d.Change)
+	}
+	return fmt.Sprintf("%+.4g", d.Diff)
+}
+
+// Class returns the bootstrap class highlighting a significant change, given
+// if higher values are better or worse
+func (d Delta) Class(higherIsBetter bool) string {
+	switch {
+	case d.Diff == 0:
+		return ""
+	case d.Change != 0 && math.Abs(d.Change) < significantChange:
+		return ""
+	case (d.Diff > 0) == higherIsBetter:
+		return "text-success"
+	}
+	return "text-danger"
+}
+
+func newStepMetrics(r requester.Report) *StepMetrics {
+	m := &StepMetrics{
+		Rps:        r.Rps,
+		Average:    r.Average,
+		P50:        r.Percentile(50),
+		P90:        r.Percentile(90),
+		P99:        r.Percentile(99),
+		ErrorRatio: r.ErrorRatio(),
+	}
+	for _, n := range r.ErrorDist {
+		m.Errors += n
+	}
+	return m
+}
+
+func (m *StepMetrics) compare(base *StepMetrics) {
+	m.Deltas = &StepDeltas{
+		Rps:        newDelta(m.Rps, base.Rps),
+		Average:    newDelta(m.Average, base.Average),
+		P50:        newDelta(m.P50, base.P50),
+		P90:        newDelta(m.P90, base.P90),
+		P99:        newDelta(m.P99, base.P99),
+		ErrorRatio: newDelta(m.ErrorRatio, base.ErrorRatio),
+	}
+}
+
+// stepKey identifies a step by its load. Plans visiting the same load more
+// than once (e.g. spikes) are aligned by occurrence.
+type stepKey struct {
+	load, occurrence int
+}
+
+// compareResults aligns the steps of the results, which must run in the same
+// mode
+func compareResults(ids []string, results []Result) (Comparison, error) {
+	cmp := Comparison{IDs: ids, Unit: "C"}
+	rate, first := false, true
+	for _, res := range results {
+		for _, r := range res.Reports {
+			if first {
+				rate, first = r.Rate > 0, false
+				continue
+			}
+			if (r.Rate > 0) != rate {
+				return cmp, fmt.Errorf("can not compare the results of the concurrency and the rate modes")
+			}
+		}
+	}
+	if rate {
+		cmp.Unit = "rps"
+	}
+
+	steps := map[stepKey][]*StepMetrics{}
+	for i, res := range results {
+		seen := map[int]int{}
+		for _, r := range res.Reports {
+			load := r.C
+			if rate {
+				load = r.Rate
+			}
+			k := stepKey{load, seen[load]}
+			seen[load]++
+			if _, ok := steps[k]; !ok {
+				steps[k] = make([]*StepMetrics, len(results))
+			}
+			steps[k][i] = newStepMetrics(r)
+		}
+	}
+
+	keys := make([]stepKey, 0, len(steps))
+	for k := range steps {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].occurrence != keys[j].occurrence {
+			return keys[i].occurrence < keys[j].occurrence
+		}
+		return keys[i].load < keys[j].load
+	})
+
+	for _, k := range keys {
+		metrics := steps[k]
+		if base := metrics[0]; base != nil {
+			for _, m := range metrics[1:] {
+				if m != nil {
+					m.compare(base)
+				}
+			}
+		}
+		cmp.Steps = append(cmp.Steps, ComparedStep{Load: k.load, Results: metrics})
+	}
+	return cmp, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+func Test_compareResults(t *testing.T) {
+	report := func(c int, rps, average float64, errors int) requester.Report {
+		r := requester.Report{C: c}
+		r.Rps = rps
+		r.Average = average
+		r.NumRes = 100
+		r.Lats = []float64{average}
+		r.ErrorDist = map[string]int{}
+		if errors > 0 {
+			r.ErrorDist["timeout"] = errors
+		}
+		return r
+	}
+	baseline := Result{Reports: []requester.Report{report(1, 100, 0.01, 0), report(2, 200, 0.01, 0), report(1, 100, 0.01, 0)}}
+	candidate := Result{Reports: []requester.Report{report(1, 102, 0.02, 5), report(4, 300, 0.03, 0), report(1, 90, 0.01, 0)}}
+
+	cmp, err := compareResults([]string{"a", "b"}, []Result{baseline, candidate})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if cmp.Unit != "C" {
+		t.Errorf("unexpected unit: %s", cmp.Unit)
+	}
+	loads := []int{}
+	for _, s := range cmp.Steps {
+		loads = append(loads, s.Load)
+	}
+	// the second visit to C=1 is aligned with the second one of the baseline
+	if len(loads) != 4 || loads[0] != 1 || loads[1] != 2 || loads[2] != 4 || loads[3] != 1 {
+		t.Errorf("unexpected loads: %v", loads)
+		return
+	}
+	if cmp.Steps[1].Results[1] != nil || cmp.Steps[2].Results[0] != nil {
+		t.Errorf("unexpected alignment: %+v", cmp.Steps)
+	}
+	if cmp.Steps[2].Results[1].Deltas != nil {
+		t.Error("steps without baseline should not have deltas")
+	}
+
+	first := cmp.Steps[0].Results[1]
+	if first.Deltas == nil {
+		t.Error("deltas expected")
+		return
+	}
+	if d := first.Deltas.Rps; d.Diff != 2 || d.Change != 2 || d.Class(true) != "" {
+		t.Errorf("unexpected rps delta: %+v", d)
+	}
+	if d := first.Deltas.Average; d.Change != 100 || d.Class(false) != "text-danger" {
+		t.Errorf("unexpected average delta: %+v", d)
+	}
+	if d := first.Deltas.ErrorRatio; d.Diff != 0.05 || d.Change != 0 || d.Class(false) != "text-danger" || d.String() != "+0.05" {
+		t.Errorf("unexpected error ratio delta: %+v", d)
+	}
+	if d := cmp.Steps[3].Results[1].Deltas.Rps; d.Change != -10 || d.Class(true) != "text-danger" || d.String() != "-10.0%" {
+		t.Errorf("unexpected rps delta: %+v", d)
+	}
+
+	rate := Result{Reports: []requester.Report{{Rate: 10}}}
+	if _, err := compareResults([]string{"a", "c"}, []Result{baseline, rate}); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltDataBucket = []byte("data")
+	boltMetaBucket = []byte("meta")
+)
+
+// ClosableDB is a DB holding resources, like open files, to release once done
+type ClosableDB interface {
+	DB
+	io.Closer
+}
+
+// NewBolt opens (or creates) the bbolt database file at the path. Every
+// operation runs in its own transaction, so renames and deletes are atomic.
+// The returned DB must be closed once done.
+func NewBolt(path string) (ClosableDB, error) {
+	b, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = b.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltDataBucket, boltMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	return &boltStore{db: b}, nil
+}
+
+type boltStore struct {
+	db *bolt.DB
+}
+
+func (b *boltStore) Close() error {
+	return b.db.Close()
+}
+
+func (b *boltStore) Get(key string) (io.Reader, error) {
+	var data []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltDataBucket).Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		// the values are only valid during the transaction
+		data = append([]byte{}, v...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(data), nil
+}
+
+func (b *boltStore) Keys() ([]string, error) {
+	res := []string{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDataBucket).ForEach(func(k, _ []byte) error {
+			res = append(res, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return []string{}, ErrUnableToList
+	}
+	return res, nil
+}
+
+func (b *boltStore) Set(key string, r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDataBucket).Put([]byte(key), data)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (b *boltStore) Delete(key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltDataBucket)
+		if data.Get([]byte(key)) == nil {
+			return ErrNotFound
+		}
+		if err := data.Delete([]byte(key)); err != nil {
+			return err
+		}
+		return tx.Bucket(boltMetaBucket).Delete([]byte(key))
+	})
+}
+
+func (b *boltStore) Rename(from, to string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltDataBucket)
+		if data.Get([]byte(from)) == nil {
+			return ErrNotFound
+		}
+		if data.Get([]byte(to)) != nil {
+			return ErrAlreadyExists
+		}
+		if err := boltMove(data, from, to); err != nil {
+			return err
+		}
+		return boltMove(tx.Bucket(boltMetaBucket), from, to)
+	})
+}
+
+func boltMove(bucket *bolt.Bucket, from, to string) error {
+	v := bucket.Get([]byte(from))
+	if v == nil {
+		return nil
+	}
+	if err := bucket.Put([]byte(to), append([]byte{}, v...)); err != nil {
+		return err
+	}
+	return bucket.Delete([]byte(from))
+}
+
+func (b *boltStore) Meta(key string) (Metadata, error) {
+	m := Metadata{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltDataBucket).Get([]byte(key)) == nil {
+			return ErrNotFound
+		}
+		v := tx.Bucket(boltMetaBucket).Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &m)
+	})
+	return m, err
+}
+
+func (b *boltStore) SetMeta(key string, m Metadata) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltDataBucket).Get([]byte(key)) == nil {
+			return ErrNotFound
+		}
+		return tx.Bucket(boltMetaBucket).Put([]byte(key), data)
+	})
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBolt(t *testing.T) {
+	store, err := NewBolt(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer store.Close()
+	testDB(t, store)
+}
+
+func TestCopy(t *testing.T) {
+	dir := t.TempDir()
+	src := NewLocalFS(dir)
+	src.Set("a", bytes.NewBufferString("a"))
+	src.Set("b", bytes.NewBufferString("b"))
+	src.SetMeta("b", Metadata{Tags: []string{"x"}})
+	src.Set("_internal", bytes.NewBufferString("src"))
+
+	path := filepath.Join(dir, "store.db")
+	dst, err := NewBolt(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dst.Set("_internal", bytes.NewBufferString("dst"))
+	skip := func(key string) bool { return strings.HasPrefix(key, "_") }
+	if n, err := Copy(dst, src, skip); err != nil || n != 2 {
+		t.Errorf("unexpected result: %d %v", n, err)
+	}
+	dst.Close()
+
+	// the imported keys persist
+	dst, err = NewBolt(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer dst.Close()
+	r, err := dst.Get("a")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if data, _ := io.ReadAll(r); string(data) != "a" {
+		t.Errorf("unexpected content: %s", data)
+	}
+	if m, err := dst.Meta("b"); err != nil || len(m.Tags) != 1 || m.Tags[0] != "x" {
+		t.Errorf("unexpected metadata: %+v %v", m, err)
+	}
+	// the skipped keys are not replaced
+	assertContent(t, dst, "_internal", "dst")
+	if keys, _ := dst.Keys(); len(keys) != 3 {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
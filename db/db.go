@@ -6,13 +6,31 @@ import (
 )
 
 var (
-	ErrNotFound     = errors.New("key not found")
-	ErrUnableToList = errors.New("unable to list keys")
-	ErrNotSet       = errors.New("unable to set the key-content pair")
+	ErrNotFound      = errors.New("key not found")
+	ErrUnableToList  = errors.New("unable to list keys")
+	ErrNotSet        = errors.New("unable to set the key-content pair")
+	ErrAlreadyExists = errors.New("key already exists")
 )
 
 type DB interface {
 	Get(key string) (io.Reader, error)
 	Keys() ([]string, error)
 	Set(key string, r io.Reader) (int, error)
+	// Delete removes the content and the metadata of the key
+	Delete(key string) error
+	// Rename moves the content and the metadata of a key to a new one, which
+	// must not exist
+	Rename(from, to string) error
+	// Meta returns the metadata of an existing key
+	Meta(key string) (Metadata, error)
+	// SetMeta replaces the metadata of an existing key
+	SetMeta(key string, m Metadata) error
+}
+
+// Metadata describes a stored test without decoding its content
+type Metadata struct {
+	Tags  []string `json:",omitempty"`
+	Notes string   `json:",omitempty"`
+	// ETag is the version of the remote object cached under the key
+	ETag string `json:",omitempty"`
 }
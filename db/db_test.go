@@ -0,0 +1,80 @@
+package db
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"testing"
+)
+
+func TestInMemory(t *testing.T) {
+	testDB(t, NewInMemory())
+}
+
+func TestFileSystem(t *testing.T) {
+	fs := fileSystem(t.TempDir())
+	testDB(t, &fs)
+}
+
+func testDB(t *testing.T, store DB) {
+	for _, key := range []string{"a", "b"} {
+		if _, err := store.Set(key, bytes.NewBufferString(key)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if err := store.SetMeta("a", Metadata{Tags: []string{"x"}, Notes: "note"}); err != nil {
+		t.Error(err)
+	}
+	if err := store.SetMeta("unknown", Metadata{}); err != ErrNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if m, err := store.Meta("b"); err != nil || len(m.Tags) != 0 || m.Notes != "" {
+		t.Errorf("unexpected metadata: %+v %v", m, err)
+	}
+
+	if err := store.Rename("a", "b"); err != ErrAlreadyExists {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := store.Rename("unknown", "c"); err != ErrNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := store.Rename("a", "c"); err != nil {
+		t.Error(err)
+	}
+	if _, err := store.Get("a"); err != ErrNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := store.Meta("a"); err != ErrNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+	r, err := store.Get("c")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if data, _ := io.ReadAll(r); string(data) != "a" {
+		t.Errorf("unexpected content: %s", data)
+	}
+	if m, err := store.Meta("c"); err != nil || len(m.Tags) != 1 || m.Tags[0] != "x" || m.Notes != "note" {
+		t.Errorf("unexpected metadata: %+v %v", m, err)
+	}
+
+	if err := store.Delete("b"); err != nil {
+		t.Error(err)
+	}
+	if err := store.Delete("b"); err != ErrNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	keys, err := store.Keys()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sort.Strings(keys)
+	if len(keys) != 1 || keys[0] != "c" {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"log"
@@ -24,6 +25,13 @@ func NewFS(path string, s *session.Session, bucket string) (DB, error) {
 	return f, nil
 }
 
+// NewLocalFS returns the store of the files of the path, without mirroring
+// them to S3
+func NewLocalFS(path string) DB {
+	fs := fileSystem(path)
+	return &fs
+}
+
 type persistedFS struct {
 	fs *fileSystem
 	s3 S3
@@ -51,7 +59,57 @@ func (f persistedFS) Set(key string, r io.Reader) (int, error) {
 	return n, nil
 }
 
-const fsBDExtension = ".json"
+func (f persistedFS) Delete(key string) error {
+	if err := f.fs.Delete(key); err != nil {
+		return err
+	}
+	go func() {
+		if err := f.s3.Delete(key); err != nil {
+			log.Printf("deleting '%s' from S3: %s", key, err)
+		}
+	}()
+	return nil
+}
+
+func (f persistedFS) Rename(from, to string) error {
+	if err := f.fs.Rename(from, to); err != nil {
+		return err
+	}
+	go func() {
+		if err := f.s3.Upload(to); err != nil {
+			log.Printf("uploading '%s' to S3: %s", to, err)
+			return
+		}
+		if err := f.s3.UploadMeta(to); err != nil {
+			log.Printf("uploading the metadata of '%s' to S3: %s", to, err)
+		}
+		if err := f.s3.Delete(from); err != nil {
+			log.Printf("deleting '%s' from S3: %s", from, err)
+		}
+	}()
+	return nil
+}
+
+func (f persistedFS) Meta(key string) (Metadata, error) {
+	return f.fs.Meta(key)
+}
+
+func (f persistedFS) SetMeta(key string, m Metadata) error {
+	if err := f.fs.SetMeta(key, m); err != nil {
+		return err
+	}
+	go func() {
+		if err := f.s3.UploadMeta(key); err != nil {
+			log.Printf("uploading the metadata of '%s' to S3: %s", key, err)
+		}
+	}()
+	return nil
+}
+
+const (
+	fsBDExtension   = ".json"
+	fsMetaExtension = ".meta"
+)
 
 type fileSystem string
 
@@ -73,7 +131,7 @@ func (f *fileSystem) Keys() ([]string, error) {
 		if file.IsDir() || !strings.Contains(file.Name(), fsBDExtension) {
 			continue
 		}
-		res = append(res, strings.TrimRight(file.Name(), fsBDExtension))
+		res = append(res, strings.TrimSuffix(file.Name(), fsBDExtension))
 	}
 	return res, nil
 }
@@ -93,3 +151,69 @@ func (f *fileSystem) Set(key string, r io.Reader) (int, error) {
 func (f *fileSystem) GetPath(key string) string {
 	return string(*f) + "/" + key + fsBDExtension
 }
+
+// GetMetaPath returns the path of the file holding the metadata of the key
+func (f *fileSystem) GetMetaPath(key string) string {
+	return string(*f) + "/" + key + fsMetaExtension
+}
+
+func (f *fileSystem) exists(key string) bool {
+	_, err := os.Stat(f.GetPath(key))
+	return err == nil
+}
+
+func (f *fileSystem) Delete(key string) error {
+	if err := os.Remove(f.GetPath(key)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if err := os.Remove(f.GetMetaPath(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *fileSystem) Rename(from, to string) error {
+	if !f.exists(from) {
+		return ErrNotFound
+	}
+	if f.exists(to) {
+		return ErrAlreadyExists
+	}
+	if err := os.Rename(f.GetPath(from), f.GetPath(to)); err != nil {
+		return err
+	}
+	if err := os.Rename(f.GetMetaPath(from), f.GetMetaPath(to)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *fileSystem) Meta(key string) (Metadata, error) {
+	m := Metadata{}
+	if !f.exists(key) {
+		return m, ErrNotFound
+	}
+	data, err := os.ReadFile(f.GetMetaPath(key))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+func (f *fileSystem) SetMeta(key string, m Metadata) error {
+	if !f.exists(key) {
+		return ErrNotFound
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.GetMetaPath(key), data, 0644)
+}
@@ -7,11 +7,14 @@ import (
 )
 
 func NewInMemory() DB {
-	return &memory{&sync.Map{}}
+	return &memory{m: &sync.Map{}, meta: &sync.Map{}}
 }
 
 type memory struct {
-	m *sync.Map
+	m    *sync.Map
+	meta *sync.Map
+	// mu serializes the operations moving or removing keys
+	mu sync.Mutex
 }
 
 func (db *memory) Get(key string) (io.Reader, error) {
@@ -43,3 +46,54 @@ func (db *memory) Set(key string, r io.Reader) (int, error) {
 	db.m.Store(key, buf.Bytes())
 	return buf.Len(), nil
 }
+
+func (db *memory) Delete(key string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.m.LoadAndDelete(key); !ok {
+		return ErrNotFound
+	}
+	db.meta.Delete(key)
+	return nil
+}
+
+func (db *memory) Rename(from, to string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	v, ok := db.m.Load(from)
+	if !ok {
+		return ErrNotFound
+	}
+	if _, loaded := db.m.LoadOrStore(to, v); loaded {
+		return ErrAlreadyExists
+	}
+	db.m.Delete(from)
+	if m, ok := db.meta.LoadAndDelete(from); ok {
+		db.meta.Store(to, m)
+	}
+	return nil
+}
+
+func (db *memory) Meta(key string) (Metadata, error) {
+	if _, ok := db.m.Load(key); !ok {
+		return Metadata{}, ErrNotFound
+	}
+	m, ok := db.meta.Load(key)
+	if !ok {
+		return Metadata{}, nil
+	}
+	return m.(Metadata), nil
+}
+
+func (db *memory) SetMeta(key string, m Metadata) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.m.Load(key); !ok {
+		return ErrNotFound
+	}
+	db.meta.Store(key, m)
+	return nil
+}
@@ -0,0 +1,49 @@
+package db
+
+import "fmt"
+
+// Copy imports every key of the src DB, with its metadata, into the dst one,
+// replacing the existing keys. The keys matching skip are left out. It returns
+// the number of keys copied.
+func Copy(dst, src DB, skip func(key string) bool) (int, error) {
+	keys, err := src.Keys()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, key := range keys {
+		if skip != nil && skip(key) {
+			continue
+		}
+		if err := copyKey(dst, src, key); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// copyKey reads everything from the src DB before writing the dst one, as
+// writing the dst one may touch the src one too (a cache sharing its path)
+func copyKey(dst, src DB, key string) error {
+	m, err := src.Meta(key)
+	if err != nil {
+		return fmt.Errorf("reading the metadata of %s: %s", key, err.Error())
+	}
+	r, err := src.Get(key)
+	if err != nil {
+		return fmt.Errorf("reading %s: %s", key, err.Error())
+	}
+	if _, err := dst.Set(key, r); err != nil {
+		return fmt.Errorf("writing %s: %s", key, err.Error())
+	}
+	// the ETags only make sense for the cache holding them
+	m.ETag = ""
+	if len(m.Tags) == 0 && m.Notes == "" {
+		return nil
+	}
+	if err := dst.SetMeta(key, m); err != nil {
+		return fmt.Errorf("writing the metadata of %s: %s", key, err.Error())
+	}
+	return nil
+}
@@ -23,8 +23,32 @@ func NewS3(s *session.Session, f *fileSystem, bucket string) (S3, error) {
 	return S3{sess: s, f: f, bucket: bucket, id: time.Now().String()}, nil
 }
 
+// Upload copies the content of the key to the bucket
 func (s S3) Upload(key string) error {
-	fileDir := s.f.GetPath(key)
+	return s.upload(s.f.GetPath(key))
+}
+
+// UploadMeta copies the metadata of the key to the bucket
+func (s S3) UploadMeta(key string) error {
+	if _, err := os.Stat(s.f.GetMetaPath(key)); os.IsNotExist(err) {
+		return nil
+	}
+	return s.upload(s.f.GetMetaPath(key))
+}
+
+// Delete removes the content and the metadata of the key from the bucket
+func (s S3) Delete(key string) error {
+	_, err := s3.New(s.sess).DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(s.bucket),
+		Delete: &s3.Delete{Objects: []*s3.ObjectIdentifier{
+			{Key: aws.String(filepath.Join(s.id, s.f.GetPath(key)))},
+			{Key: aws.String(filepath.Join(s.id, s.f.GetMetaPath(key)))},
+		}},
+	})
+	return err
+}
+
+func (s S3) upload(fileDir string) error {
 	// Open the file for use
 	file, err := os.Open(fileDir)
 	if err != nil {
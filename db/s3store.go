@@ -0,0 +1,281 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	s3DataDir = "data"
+	s3MetaDir = "meta"
+)
+
+// NewS3Store returns a DB keeping the content and the metadata of every key
+// in the bucket, under the prefix. The keys of the objects only depend on the
+// prefix, so every instance sharing it sees the same tests.
+//
+// The contents read or written are kept in the cache, with the ETag of the
+// object as their metadata. They are revalidated against the object on every
+// read, so the unchanged objects are not downloaded again, even after a
+// restart when the cache persists.
+func NewS3Store(s *session.Session, bucket, prefix string, cache DB) DB {
+	return &s3Store{
+		client: s3.New(s),
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+		cache:  cache,
+	}
+}
+
+type s3Store struct {
+	client *s3.S3
+	bucket string
+	prefix string
+	cache  DB
+}
+
+func (s *s3Store) objectKey(dir, key string) string {
+	return path.Join(s.prefix, dir, key+fsBDExtension)
+}
+
+// etag returns the ETag of the cached content of the key, if any
+func (s *s3Store) etag(key string) string {
+	m, err := s.cache.Meta(key)
+	if err != nil {
+		return ""
+	}
+	return m.ETag
+}
+
+// cacheContent keeps the content of the key and its ETag in the cache. The
+// metadata with tags or notes is never replaced, as it does not belong to the
+// cache but to a store sharing its path.
+func (s *s3Store) cacheContent(key string, data []byte, etag *string) {
+	if _, err := s.cache.Set(key, bytes.NewReader(data)); err != nil {
+		log.Printf("caching '%s': %s", key, err.Error())
+		return
+	}
+	if m, err := s.cache.Meta(key); err == nil && (len(m.Tags) > 0 || m.Notes != "") {
+		log.Printf("not caching the ETag of '%s': its metadata is not from the cache", key)
+		return
+	}
+	if err := s.cache.SetMeta(key, Metadata{ETag: aws.StringValue(etag)}); err != nil {
+		log.Printf("caching the ETag of '%s': %s", key, err.Error())
+	}
+}
+
+func (s *s3Store) Get(key string) (io.Reader, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(s3DataDir, key)),
+	}
+	if etag := s.etag(key); etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+	out, err := s.client.GetObject(input)
+	if isNotModified(err) {
+		if r, err := s.cache.Get(key); err == nil {
+			return r, nil
+		}
+		input.IfNoneMatch = nil
+		out, err = s.client.GetObject(input)
+	}
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.cacheContent(key, data, out.ETag)
+	return bytes.NewBuffer(data), nil
+}
+
+func (s *s3Store) Keys() ([]string, error) {
+	dir := path.Join(s.prefix, s3DataDir) + "/"
+	res := []string{}
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(dir),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, o := range page.Contents {
+			name := strings.TrimPrefix(aws.StringValue(o.Key), dir)
+			if strings.Contains(name, "/") || !strings.HasSuffix(name, fsBDExtension) {
+				continue
+			}
+			res = append(res, strings.TrimSuffix(name, fsBDExtension))
+		}
+		return true
+	})
+	if err != nil {
+		log.Printf("listing the bucket: %s", err.Error())
+		return []string{}, ErrUnableToList
+	}
+	return res, nil
+}
+
+func (s *s3Store) Set(key string, r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	out, err := s.put(s3DataDir, key, data)
+	if err != nil {
+		return 0, err
+	}
+	s.cacheContent(key, data, out.ETag)
+	return len(data), nil
+}
+
+func (s *s3Store) put(dir, key string, data []byte) (*s3.PutObjectOutput, error) {
+	return s.client.PutObject(&s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(s.objectKey(dir, key)),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   aws.String("application/json"),
+	})
+}
+
+func (s *s3Store) exists(key string) (bool, error) {
+	_, err := s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(s3DataDir, key)),
+	})
+	switch {
+	case err == nil:
+		return true, nil
+	case isS3NotFound(err):
+		return false, nil
+	}
+	return false, err
+}
+
+// checkExists returns ErrNotFound if the key is not stored
+func (s *s3Store) checkExists(key string) error {
+	ok, err := s.exists(key)
+	if err == nil && !ok {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *s3Store) Delete(key string) error {
+	if err := s.checkExists(key); err != nil {
+		return err
+	}
+	_, err := s.client.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(s.bucket),
+		Delete: &s3.Delete{Objects: []*s3.ObjectIdentifier{
+			{Key: aws.String(s.objectKey(s3DataDir, key))},
+			{Key: aws.String(s.objectKey(s3MetaDir, key))},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.cache.Delete(key); err != nil && err != ErrNotFound {
+		log.Printf("removing '%s' from the cache: %s", key, err.Error())
+	}
+	return nil
+}
+
+// Rename copies the objects of the key to the new one and deletes the old
+// ones. S3 has no atomic rename, so a failure can leave both keys behind.
+func (s *s3Store) Rename(from, to string) error {
+	if err := s.checkExists(from); err != nil {
+		return err
+	}
+	switch ok, err := s.exists(to); {
+	case err != nil:
+		return err
+	case ok:
+		return ErrAlreadyExists
+	}
+	for _, dir := range []string{s3DataDir, s3MetaDir} {
+		_, err := s.client.CopyObject(&s3.CopyObjectInput{
+			Bucket:     aws.String(s.bucket),
+			CopySource: aws.String(copySource(s.bucket, s.objectKey(dir, from))),
+			Key:        aws.String(s.objectKey(dir, to)),
+		})
+		if err != nil && !(dir == s3MetaDir && isS3NotFound(err)) {
+			return err
+		}
+	}
+	return s.Delete(from)
+}
+
+// copySource returns the URL-encoded path of the object, as expected by the
+// copy requests. The '+' is escaped too, so it is not read as a space.
+func copySource(bucket, key string) string {
+	segments := strings.Split(path.Join(bucket, key), "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
+
+// Meta reads the metadata object of the key. The content object is only
+// checked when the key has no metadata.
+func (s *s3Store) Meta(key string) (Metadata, error) {
+	m := Metadata{}
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(s3MetaDir, key)),
+	})
+	if isS3NotFound(err) {
+		return m, s.checkExists(key)
+	}
+	if err != nil {
+		return m, err
+	}
+	defer out.Body.Close()
+	err = json.NewDecoder(out.Body).Decode(&m)
+	return m, err
+}
+
+func (s *s3Store) SetMeta(key string, m Metadata) error {
+	if err := s.checkExists(key); err != nil {
+		return err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = s.put(s3MetaDir, key, data)
+	return err
+}
+
+func isS3NotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	if e, ok := err.(awserr.RequestFailure); ok && e.StatusCode() == http.StatusNotFound {
+		return true
+	}
+	if e, ok := err.(awserr.Error); ok && e.Code() == s3.ErrCodeNoSuchKey {
+		return true
+	}
+	return false
+}
+
+func isNotModified(err error) bool {
+	e, ok := err.(awserr.RequestFailure)
+	return ok && e.StatusCode() == http.StatusNotModified
+}
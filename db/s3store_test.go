@@ -0,0 +1,289 @@
+package db
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestS3Store(t *testing.T) {
+	srv := newFakeS3()
+	defer srv.Close()
+
+	testDB(t, NewS3Store(srv.session(t), "bucket", "results", NewInMemory()))
+}
+
+func TestS3Store_sharedBucket(t *testing.T) {
+	srv := newFakeS3()
+	defer srv.Close()
+
+	a := NewS3Store(srv.session(t), "bucket", "results", NewInMemory())
+	if _, err := a.Set("test", bytes.NewBufferString("first")); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// a fresh instance sees the results stored by the previous one
+	b := NewS3Store(srv.session(t), "bucket", "results/", NewInMemory())
+	if keys, err := b.Keys(); err != nil || len(keys) != 1 || keys[0] != "test" {
+		t.Errorf("unexpected keys: %v %v", keys, err)
+	}
+	for i := 0; i < 3; i++ {
+		assertContent(t, b, "test", "first")
+	}
+	if n := srv.downloads(); n != 1 {
+		t.Errorf("unexpected number of downloads: %d", n)
+	}
+
+	// the cached content is refreshed once the object changes
+	a.Set("test", bytes.NewBufferString("second"))
+	assertContent(t, b, "test", "second")
+	if n := srv.downloads(); n != 2 {
+		t.Errorf("unexpected number of downloads: %d", n)
+	}
+
+	// other prefixes are isolated
+	c := NewS3Store(srv.session(t), "bucket", "others", NewInMemory())
+	if keys, _ := c.Keys(); len(keys) != 0 {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
+
+func TestS3Store_persistedCache(t *testing.T) {
+	srv := newFakeS3()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	a := NewS3Store(srv.session(t), "bucket", "results", NewLocalFS(dir))
+	a.Set("test", bytes.NewBufferString("content"))
+	a.SetMeta("test", Metadata{Tags: []string{"x"}})
+
+	// a new instance using the same cache revalidates its content instead of
+	// downloading it again
+	b := NewS3Store(srv.session(t), "bucket", "results", NewLocalFS(dir))
+	assertContent(t, b, "test", "content")
+	if n := srv.downloads(); n != 0 {
+		t.Errorf("unexpected number of downloads: %d", n)
+	}
+	if m, err := b.Meta("test"); err != nil || len(m.Tags) != 1 || m.ETag != "" {
+		t.Errorf("unexpected metadata: %+v %v", m, err)
+	}
+}
+
+func TestS3Store_copyFromCache(t *testing.T) {
+	srv := newFakeS3()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	src := NewLocalFS(dir)
+	src.Set("a", bytes.NewBufferString("a"))
+	src.SetMeta("a", Metadata{Tags: []string{"x"}, Notes: "n"})
+
+	// the cache of the S3 store is the source of the copy
+	dst := NewS3Store(srv.session(t), "bucket", "results", NewLocalFS(dir))
+	if n, err := Copy(dst, src, nil); err != nil || n != 1 {
+		t.Errorf("unexpected result: %d %v", n, err)
+		return
+	}
+	for name, store := range map[string]DB{"src": src, "dst": dst} {
+		if m, err := store.Meta("a"); err != nil || len(m.Tags) != 1 || m.Tags[0] != "x" || m.Notes != "n" {
+			t.Errorf("unexpected metadata of the %s: %+v %v", name, m, err)
+		}
+	}
+	assertContent(t, dst, "a", "a")
+}
+
+func TestS3Store_renameEscaped(t *testing.T) {
+	srv := newFakeS3()
+	defer srv.Close()
+
+	store := NewS3Store(srv.session(t), "bucket", "results", NewInMemory())
+	store.Set("a", bytes.NewBufferString("a"))
+	store.SetMeta("a", Metadata{Notes: "n"})
+	for _, name := range []string{"with space", "a+b", "100%", "ünïcode"} {
+		if err := store.Rename("a", name); err != nil {
+			t.Errorf("renaming to %s: %s", name, err.Error())
+			return
+		}
+		assertContent(t, store, name, "a")
+		if m, err := store.Meta(name); err != nil || m.Notes != "n" {
+			t.Errorf("unexpected metadata of %s: %+v %v", name, m, err)
+		}
+		if err := store.Rename(name, "a"); err != nil {
+			t.Errorf("renaming from %s: %s", name, err.Error())
+			return
+		}
+	}
+}
+
+func assertContent(t *testing.T, store DB, key, content string) {
+	r, err := store.Get(key)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if data, _ := io.ReadAll(r); string(data) != content {
+		t.Errorf("unexpected content: %s", data)
+	}
+}
+
+// fakeS3 is a minimal S3-compatible server, keeping the objects of every
+// bucket in memory. It only supports the path-style requests.
+type fakeS3 struct {
+	*httptest.Server
+	mu      sync.Mutex
+	objects map[string][]byte
+	gets    int
+}
+
+func newFakeS3() *fakeS3 {
+	f := &fakeS3{objects: map[string][]byte{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+func (f *fakeS3) session(t *testing.T) *session.Session {
+	s, err := session.NewSession(&aws.Config{
+		Endpoint:         aws.String(f.URL),
+		Region:           aws.String("us-east-1"),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (f *fakeS3) downloads() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gets
+}
+
+func etag(data []byte) string {
+	return fmt.Sprintf("\"%x\"", md5.Sum(data))
+}
+
+func (f *fakeS3) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket, key := parts[0], ""
+	if len(parts) > 1 {
+		key = parts[1]
+	}
+	path := bucket + "/" + key
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, bucket, r.URL.Query().Get("prefix"))
+	case r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+		f.delete(w, r, bucket)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		src, err := copySourcePath(r.Header.Get("X-Amz-Copy-Source"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "<Error><Code>InvalidArgument</Code><Message>%s</Message></Error>", err.Error())
+			return
+		}
+		data, ok := f.objects[src]
+		if !ok {
+			notFound(w, r)
+			return
+		}
+		f.objects[path] = data
+		fmt.Fprintf(w, "<CopyObjectResult><ETag>%s</ETag></CopyObjectResult>", etag(data))
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[path] = data
+		w.Header().Set("ETag", etag(data))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[path]
+		if !ok {
+			notFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", etag(data))
+		if r.Header.Get("If-None-Match") == etag(data) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if r.Method == http.MethodGet {
+			f.gets++
+			w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// copySourcePath decodes the source of a copy, rejecting the raw values S3
+// would misread
+func copySourcePath(source string) (string, error) {
+	for _, c := range source {
+		if c == ' ' || c == '+' || c > unicode.MaxASCII {
+			return "", fmt.Errorf("unescaped copy source: %s", source)
+		}
+	}
+	return url.PathUnescape(strings.TrimPrefix(source, "/"))
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	if r.Method != http.MethodHead {
+		fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, bucket, prefix string) {
+	keys := []string{}
+	for path := range f.objects {
+		if key := strings.TrimPrefix(path, bucket+"/"); key != path && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	res := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		IsTruncated bool
+		Contents    []struct{ Key string }
+	}{Name: bucket, Prefix: prefix, KeyCount: len(keys)}
+	for _, k := range keys {
+		res.Contents = append(res.Contents, struct{ Key string }{k})
+	}
+	xml.NewEncoder(w).Encode(res)
+}
+
+func (f *fakeS3) delete(w http.ResponseWriter, r *http.Request, bucket string) {
+	req := struct {
+		Object []struct{ Key string }
+	}{}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, o := range req.Object {
+		delete(f.objects, bucket+"/"+o.Key)
+	}
+	fmt.Fprint(w, "<DeleteResult></DeleteResult>")
+}
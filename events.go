@@ -0,0 +1,178 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+type EventType string
+
+const (
+	EventPlanStart EventType = "plan_start"
+	EventStepStart EventType = "step_start"
+	EventStepEnd   EventType = "step_end"
+	EventPlanEnd   EventType = "plan_end"
+)
+
+// Event describes the progress of a plan execution. The Step of the step
+// events is its position, starting at 1, and the one of the plan end is the
+// number of steps run.
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	Step     int
+	C        int
+	Rate     int     `json:",omitempty"`
+	Rps      float64 `json:",omitempty"`
+	Average  float64 `json:",omitempty"`
+	Fastest  float64 `json:",omitempty"`
+	Slowest  float64 `json:",omitempty"`
+	P99      float64 `json:",omitempty"`
+	Requests int64   `json:",omitempty"`
+	Errors   int     `json:",omitempty"`
+	Dropped  int64   `json:",omitempty"`
+	Late     int64   `json:",omitempty"`
+	Error    string  `json:",omitempty"`
+	Reason   string  `json:",omitempty"`
+}
+
+// newStepEndEvent returns the end of the step with the given index
+func newStepEndEvent(i int, report requester.Report) Event {
+	e := Event{
+		Type:     EventStepEnd,
+		Time:     time.Now(),
+		Step:     i + 1,
+		C:        report.C,
+		Rate:     report.Rate,
+		Rps:      report.Rps,
+		Average:  report.Average,
+		Fastest:  report.Fastest,
+		Slowest:  report.Slowest,
+		Requests: report.NumRes,
+		Dropped:  report.Dropped,
+		Late:     report.Late,
+	}
+	for _, ld := range report.LatencyDistribution {
+		if ld.Percentage == 99 {
+			e.P99 = ld.Latency
+		}
+	}
+	for _, n := range report.ErrorDist {
+		e.Errors += n
+	}
+	return e
+}
+
+// closedTopicTTL is how long the history of a finished plan is kept
+const closedTopicTTL = time.Hour
+
+// Broker dispatches the events of every plan to its subscribers. It keeps the
+// history of each plan, so late subscribers can catch up, until a while after
+// the plan ends.
+type Broker struct {
+	mu     sync.Mutex
+	topics map[string]*topic
+}
+
+type topic struct {
+	history []Event
+	subs    map[chan Event]struct{}
+	closed  bool
+	// closedAt is the time the topic was closed
+	closedAt time.Time
+}
+
+func NewBroker() *Broker {
+	return &Broker{topics: map[string]*topic{}}
+}
+
+func (b *Broker) Publish(id string, e Event) {
+	if b == nil || id == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topic(id)
+	if t.closed {
+		return
+	}
+	t.history = append(t.history, e)
+	for ch := range t.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Close marks the end of the events for the given plan
+func (b *Broker) Close(id string) {
+	if b == nil || id == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	t := b.topic(id)
+	t.closed = true
+	t.closedAt = now
+	for ch := range t.subs {
+		close(ch)
+	}
+	t.subs = map[chan Event]struct{}{}
+	b.prune(now)
+}
+
+// prune drops the topics closed for longer than closedTopicTTL. It must be
+// called with the lock held.
+func (b *Broker) prune(now time.Time) {
+	for id, t := range b.topics {
+		if t.closed && now.Sub(t.closedAt) > closedTopicTTL {
+			delete(b.topics, id)
+		}
+	}
+}
+
+// Subscribe returns the events already published for the plan and a channel
+// with the upcoming ones. The channel is closed once the plan ends.
+func (b *Broker) Subscribe(id string) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topic(id)
+	history := make([]Event, len(t.history))
+	copy(history, t.history)
+
+	ch := make(chan Event, 64)
+	if t.closed {
+		close(ch)
+		return history, ch, func() {}
+	}
+	t.subs[ch] = struct{}{}
+
+	return history, ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := t.subs[ch]; ok {
+			delete(t.subs, ch)
+			close(ch)
+		}
+		// the topics without events are created again when needed
+		if !t.closed && len(t.subs) == 0 && len(t.history) == 0 && b.topics[id] == t {
+			delete(b.topics, id)
+		}
+	}
+}
+
+func (b *Broker) topic(id string) *topic {
+	t, ok := b.topics[id]
+	if !ok {
+		t = &topic{subs: map[chan Event]struct{}{}}
+		b.topics[id] = t
+	}
+	return t
+}
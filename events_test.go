@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBroker_prune(t *testing.T) {
+	b := NewBroker()
+	b.Publish("old", Event{Type: EventPlanStart})
+	b.Close("old")
+	b.topics["old"].closedAt = time.Now().Add(-2 * closedTopicTTL)
+
+	b.Publish("new", Event{Type: EventPlanStart})
+	b.Close("new")
+	if _, ok := b.topics["old"]; ok {
+		t.Error("the old topic was not pruned")
+	}
+	if history, _, _ := b.Subscribe("new"); len(history) != 1 {
+		t.Errorf("unexpected history: %v", history)
+	}
+
+	// the topics without events are dropped once nobody listens to them
+	_, _, unsubscribe := b.Subscribe("queued")
+	unsubscribe()
+	if len(b.topics) != 1 {
+		t.Errorf("unexpected topics: %v", b.topics)
+	}
+}
@@ -1,31 +1,110 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/kpacha/load-test/db"
 	"github.com/kpacha/load-test/requester"
 )
 
+type PlanMode string
+
+const (
+	// ModeConcurrency ramps the number of concurrent workers (closed model)
+	ModeConcurrency PlanMode = "concurrency"
+	// ModeRate ramps the target arrival rate of the requests (open model)
+	ModeRate PlanMode = "rate"
+)
+
+type Engine string
+
+const (
+	// EngineNative records the raw samples of every request
+	EngineNative Engine = "native"
+	// EngineHey runs the concurrency steps with hey, only keeping its summary
+	EngineHey Engine = "hey"
+)
+
 type Plan struct {
+	ID       string
 	Name     string
+	Mode     PlanMode `json:",omitempty"`
+	Engine   Engine   `json:",omitempty"`
 	Min      int
 	Max      int
 	Steps    int
-	Request  *http.Request
+	Request  requester.Request
 	Duration time.Duration
 	Sleep    time.Duration
+	Priority int
+	// Mix replaces the Request with a set of weighted requests
+	Mix []requester.Request `json:",omitempty"`
+	// Scenario replaces the Request with a sequence of chained requests
+	Scenario []requester.ScenarioStep `json:",omitempty"`
+	// Feeder holds the rows feeding the variables of the request templates
+	Feeder *requester.Feeder `json:",omitempty"`
+	// SLO turns the plan into a capacity search
+	SLO *SLO `json:",omitempty"`
+	// Schedule selects how the steps are generated. Custom schedules run the
+	// Stages list
+	Schedule Schedule `json:",omitempty"`
+	Stages   []Step   `json:",omitempty"`
+	// Warmup runs before the first step and its results are kept apart
+	Warmup *Warmup `json:",omitempty"`
+	// Stop defines when the ramp must be aborted
+	Stop *StopConditions `json:",omitempty"`
+	// Interval is the size of the buckets of the time series of every step
+	Interval time.Duration `json:",omitempty"`
+	// ExpectedInterval enables the correction of the latencies for
+	// coordinated omission, given the time expected between the requests of
+	// every worker. It only applies to the concurrency mode
+	ExpectedInterval time.Duration `json:",omitempty"`
+	// Tags group the plans sharing a baseline
+	Tags []string `json:",omitempty"`
 }
 
 func (e Plan) String() string {
-	return fmt.Sprintf("C: %d [%d-%d], Duration: %s", e.Steps, e.Min, e.Max, e.Duration.String())
+	if e.SLO != nil {
+		return fmt.Sprintf("Search %s [%d-%d] for %s, Duration: %s", e.loadUnit(), e.Min, e.Max, e.SLO.String(), e.Duration.String())
+	}
+	if e.Schedule == ScheduleCustom {
+		return fmt.Sprintf("Custom %s: %d steps, Duration: %s", e.loadUnit(), len(e.Stages), e.Duration.String())
+	}
+	schedule := ""
+	if e.Schedule != "" && e.Schedule != ScheduleLinear {
+		schedule = fmt.Sprintf(", Schedule: %s", e.Schedule)
+	}
+	if e.Mode == ModeRate {
+		return fmt.Sprintf("Rate: %d [%d-%d] rps, Duration: %s%s", e.Steps, e.Min, e.Max, e.Duration.String(), schedule)
+	}
+	return fmt.Sprintf("C: %d [%d-%d], Duration: %s%s", e.Steps, e.Min, e.Max, e.Duration.String(), schedule)
+}
+
+// workload returns the workload of the plans with a mix, a scenario, a feeder
+// or a request with templates
+func (e Plan) workload() *requester.Workload {
+	if len(e.Mix) == 0 && len(e.Scenario) == 0 {
+		if e.Feeder == nil && !e.Request.IsTemplate() {
+			return nil
+		}
+		return &requester.Workload{Mix: []requester.Request{e.Request}, Feeder: e.Feeder}
+	}
+	return &requester.Workload{Mix: e.Mix, Scenario: e.Scenario, Feeder: e.Feeder}
+}
+
+func (e Plan) loadUnit() string {
+	if e.Mode == ModeRate {
+		return "rate"
+	}
+	return "C"
 }
 
 type Executor interface {
@@ -34,70 +113,263 @@ type Executor interface {
 
 type RequesterFactory func(req *http.Request, timeout time.Duration) requester.Requester
 
-func NewExecutor(store db.DB) Executor {
-	return &executor{store, requester.NewJSON}
+type WorkloadRequesterFactory func(w requester.Workload, timeout time.Duration) (requester.Requester, error)
+
+func NewExecutor(store db.DB, events *Broker) Executor {
+	return &executor{
+		DB:                           store,
+		RequesterFactory:             requester.NewNativeJSON,
+		HeyRequesterFactory:          requester.NewJSON,
+		RateRequesterFactory:         requester.NewRateJSON,
+		WorkloadRequesterFactory:     requester.NewWorkloadJSON,
+		RateWorkloadRequesterFactory: requester.NewRateWorkloadJSON,
+		Events:                       events,
+	}
 }
 
 type executor struct {
-	DB               db.DB
-	RequesterFactory RequesterFactory
+	DB                           db.DB
+	RequesterFactory             RequesterFactory
+	HeyRequesterFactory          RequesterFactory
+	RateRequesterFactory         RequesterFactory
+	WorkloadRequesterFactory     WorkloadRequesterFactory
+	RateWorkloadRequesterFactory WorkloadRequesterFactory
+	Events                       *Broker
 }
 
-func (e *executor) Run(ctx context.Context, plan Plan) ([]requester.Report, error) {
-	report, err := e.executePlan(ctx, plan)
+func (e *executor) Run(ctx context.Context, plan Plan) (report []requester.Report, err error) {
+	res := &Result{Plan: &plan, Status: ResultCompleted, Reports: []requester.Report{}, Created: time.Now()}
+
+	e.Events.Publish(plan.ID, Event{Type: EventPlanStart, Time: time.Now()})
+	defer func() {
+		end := Event{Type: EventPlanEnd, Time: time.Now(), Step: len(report), Reason: res.StopReason}
+		if err != nil {
+			end.Error = err.Error()
+		}
+		e.Events.Publish(plan.ID, end)
+		e.Events.Close(plan.ID)
+	}()
+
+	err = e.executePlan(ctx, plan, res)
+	report = res.Reports
 	if err != nil {
-		return report, fmt.Errorf("executing the plan: %s", err.Error())
+		if ctx.Err() == nil || len(report) == 0 {
+			return report, fmt.Errorf("executing the plan: %s", err.Error())
+		}
+		log.Printf("plan cancelled after %d steps, storing the partial results", len(report))
+		res.Status = ResultCancelled
 	}
+	if res.Status != ResultCancelled {
+		res.Regression = e.checkRegression(plan, *res)
+	}
+	res.Finished = time.Now()
 
-	data := &bytes.Buffer{}
-	if err := json.NewEncoder(data).Encode(report); err != nil {
-		return report, fmt.Errorf("encoding the report: %s", err.Error())
+	data, encErr := encodeResult(*res)
+	if encErr != nil {
+		return report, fmt.Errorf("encoding the report: %s", encErr.Error())
 	}
 
-	if _, err = e.DB.Set(plan.Name, data); err != nil {
-		return report, fmt.Errorf("storing the results: %s", err.Error())
+	if _, storeErr := e.DB.Set(plan.Name, data); storeErr != nil {
+		return report, fmt.Errorf("storing the results: %s", storeErr.Error())
+	}
+	invalidateCache(plan.Name)
+	if indexErr := indexTest(e.DB, plan.Name, *res); indexErr != nil {
+		log.Printf("indexing the results: %s", indexErr.Error())
+	}
+	if err != nil {
+		return report, fmt.Errorf("executing the plan: %s", err.Error())
 	}
 	log.Println("plan execution completed")
 	return report, nil
 }
 
-func (e *executor) executePlan(ctx context.Context, plan Plan) ([]requester.Report, error) {
-	work.Lock()
-	defer work.Unlock()
+// checkRegression evaluates the result against the baseline of the plan, if
+// there is one. Failing to load the baseline does not fail the plan.
+func (e *executor) checkRegression(plan Plan, res Result) *Verdict {
+	b, err := baselineFor(e.DB, plan)
+	if err != nil {
+		log.Printf("loading the baseline: %s", err.Error())
+		return nil
+	}
+	if b == nil {
+		return nil
+	}
+	v := b.evaluate(res)
+	if !v.Passed {
+		log.Printf("regression detected against the baseline %s: %s", b.ID, strings.Join(v.Violations, "; "))
+	}
+	return &v
+}
+
+func (e *executor) executePlan(ctx context.Context, plan Plan, res *Result) error {
+	var steps []Step
+	if plan.SLO == nil {
+		var err error
+		if steps, err = plan.schedule(); err != nil {
+			return err
+		}
+	} else if err := plan.validateSearch(); err != nil {
+		return err
+	}
+
+	requestr, err := e.newRequester(plan, plan.timeout(steps))
+	if err != nil {
+		return err
+	}
+
+	if plan.Warmup != nil && plan.Warmup.Enabled() {
+		load := max(plan.Min, 1)
+		if len(steps) > 0 {
+			load = steps[0].Load
+		}
+		if err := e.warmup(ctx, plan, requestr, load, res); err != nil {
+			return err
+		}
+	}
 
-	results := []requester.Report{}
-	requestr := e.RequesterFactory(plan.Request, plan.Duration)
+	if plan.SLO != nil {
+		return e.searchCapacity(ctx, plan, requestr, res)
+	}
 
-	for i := plan.Min; i < plan.Max; i += plan.Steps {
-		log.Println("waiting before the next batch...")
-		time.Sleep(plan.Sleep)
-		select {
-		case <-ctx.Done():
-			return results, fmt.Errorf("executing the step #%d of the plan: %s", i, ctx.Err())
-		default:
+	var prev *requester.Report
+	for i, step := range steps {
+		label := fmt.Sprintf("step #%d (%s)", i+1, plan.loadLabel(step.Load))
+		report, err := e.runStep(ctx, plan, requestr, step, res)
+		if errors.Is(err, errNothingSent) && len(res.Reports) > 0 {
+			stop(res, label, errNothingSent.Error())
+			return nil
+		}
+		if err != nil {
+			return err
 		}
-		log.Printf("runing with C=%d ...\n", i)
+		if plan.Stop != nil {
+			if reason := plan.Stop.Check(prev, report); reason != "" {
+				stop(res, label, reason)
+				return nil
+			}
+		}
+		prev = &report
+	}
 
-		localCtx := ctx
-		if plan.Duration > 0 {
-			lctx, localCancel := context.WithTimeout(ctx, plan.Duration)
-			defer localCancel()
-			localCtx = lctx
+	return nil
+}
+
+// stop marks the result as stopped after the step with the label
+func stop(res *Result, label, reason string) {
+	log.Printf("stopping the plan after the %s: %s", label, reason)
+	res.Status = ResultStopped
+	res.StopReason = fmt.Sprintf("%s: %s", label, reason)
+}
+
+// newRequester builds the requester for the plan mode, sending its single
+// request, its mix of requests or its scenario
+func (e *executor) newRequester(plan Plan, timeout time.Duration) (requester.Requester, error) {
+	if w := plan.workload(); w != nil {
+		factory := e.WorkloadRequesterFactory
+		if plan.Mode == ModeRate {
+			factory = e.RateWorkloadRequesterFactory
+		}
+		requestr, err := factory(*w, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("building the workload: %s", err.Error())
 		}
+		return requestr, nil
+	}
+
+	req, err := plan.Request.HTTPRequest()
+	if err != nil {
+		return nil, fmt.Errorf("building the request: %s", err.Error())
+	}
+	factory := e.RequesterFactory
+	switch {
+	case plan.Mode == ModeRate:
+		factory = e.RateRequesterFactory
+	case plan.Engine == EngineHey:
+		factory = e.HeyRequesterFactory
+	}
+	return factory(req, timeout), nil
+}
+
+// errNothingSent is returned by the steps not sending any request
+var errNothingSent = errors.New("no requests sent")
+
+// loadLabel describes the load of a step, depending on the plan mode
+func (e Plan) loadLabel(load int) string {
+	if e.Mode == ModeRate {
+		return fmt.Sprintf("%d rps", load)
+	}
+	return fmt.Sprintf("C=%d", load)
+}
 
-		r := requestr.Run(localCtx, i)
+// runStep runs the requester with the load of the step (concurrency or rate,
+// depending on the plan mode) and appends its report to the result
+func (e *executor) runStep(ctx context.Context, plan Plan, requestr requester.Requester, step Step, res *Result) (requester.Report, error) {
+	report := requester.Report{}
+	load := step.Load
+	n := len(res.Reports) + 1
 
-		report := requester.Report{}
-		if err := json.NewDecoder(r).Decode(&report); err != nil {
-			return results, fmt.Errorf("decoding the results: %s", err.Error())
+	log.Println("waiting before the next batch...")
+	if err := sleep(ctx, step.Sleep); err != nil {
+		return report, fmt.Errorf("executing the step #%d of the plan: %s", n, err.Error())
+	}
+	start := Event{Type: EventStepStart, Time: time.Now(), Step: n}
+	if plan.Mode == ModeRate {
+		log.Printf("runing with rate=%d rps ...\n", load)
+		start.Rate = load
+	} else {
+		log.Printf("runing with C=%d ...\n", load)
+		start.C = load
+	}
+	e.Events.Publish(plan.ID, start)
+
+	var r io.Reader
+	if timed, ok := requestr.(requester.TimedRequester); ok && step.Duration > 0 {
+		// the requests in flight at the end of the step are still measured
+		r = timed.RunFor(ctx, load, step.Duration)
+	} else {
+		localCtx, localCancel := ctx, func() {}
+		if step.Duration > 0 {
+			localCtx, localCancel = context.WithTimeout(ctx, step.Duration)
 		}
-		report.C = i
-		report.URL = plan.Request.URL.String()
+		r = requestr.Run(localCtx, load)
+		localCancel()
+	}
 
-		results = append(results, report)
+	// the step was interrupted, so its partial results are discarded
+	if err := ctx.Err(); err != nil {
+		return report, fmt.Errorf("executing the step #%d of the plan: %s", n, err.Error())
 	}
 
-	return results, nil
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return report, fmt.Errorf("decoding the results: %s", err.Error())
+	}
+	// an empty step (e.g. an exhausted feeder) is not a result
+	if report.NumRes == 0 && report.Dropped == 0 {
+		return report, fmt.Errorf("executing the step #%d of the plan: %w", n, errNothingSent)
+	}
+	if plan.Mode == ModeRate {
+		report.Rate = load
+	} else {
+		report.C = load
+	}
+	report.URL = plan.Request.URL
+	report.TimeSeries = requester.TimeSeries(report.Samples, plan.Interval)
+	if plan.ExpectedInterval > 0 && plan.Mode != ModeRate {
+		report.Correct(plan.ExpectedInterval)
+	}
+
+	e.Events.Publish(plan.ID, newStepEndEvent(len(res.Reports), report))
+	res.Reports = append(res.Reports, report)
+
+	return report, nil
 }
 
-var work = &sync.Mutex{}
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+	return ctx.Err()
+}
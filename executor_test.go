@@ -3,19 +3,21 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/kpacha/load-test/db"
 	"github.com/kpacha/load-test/requester"
 )
 
 func TestNewExecutor_Run_contextCanceled(t *testing.T) {
 	store := db.NewInMemory()
-	exec := NewExecutor(store)
+	exec := NewExecutor(store, NewBroker())
 	p := Plan{
 		Min:      1,
 		Max:      10,
@@ -34,6 +36,14 @@ func TestNewExecutor_Run_contextCanceled(t *testing.T) {
 			t.Errorf("unexpected error: %s", err.Error())
 		}
 	}
+
+	// the steps are numbered by their position, not by their load
+	p.Min = 5
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := exec.Run(ctx, p); err == nil || err.Error() != "executing the plan: executing the step #1 of the plan: context canceled" {
+		t.Errorf("unexpected error: %v", err)
+	}
 }
 
 func Test_executor_Run_wrongReportFormat(t *testing.T) {
@@ -41,9 +51,9 @@ func Test_executor_Run_wrongReportFormat(t *testing.T) {
 	totalCalls := 0
 	exec := executor{
 		DB: store,
-		RequesterFactory: func(req *http.Request) requester.Requester {
-			totalCalls++
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
 			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				totalCalls++
 				if totalCalls != c {
 					t.Errorf("unexpected number of calls. have %d want %d", totalCalls, c)
 				}
@@ -74,29 +84,24 @@ func Test_executor_Run_koStore(t *testing.T) {
 	totalCalls := 0
 	exec := executor{
 		DB: store,
-		RequesterFactory: func(req *http.Request) requester.Requester {
-			totalCalls++
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
 			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				totalCalls++
 				if totalCalls != c {
 					t.Errorf("unexpected number of calls. have %d want %d", totalCalls, c)
 				}
-				return bytes.NewBufferString("{}")
+				return bytes.NewBufferString(`{"NumRes":1}`)
 			})
 		},
 	}
-	req, err := http.NewRequest("GET", "/", nil)
-	if err != nil {
-		t.Errorf("building the request: %s", err.Error())
-		return
-	}
 	p := Plan{
 		Min:      1,
 		Max:      10,
 		Steps:    1,
 		Duration: 1,
-		Request:  req,
+		Request:  requester.Request{Method: "GET", URL: "/"},
 	}
-	_, err = exec.Run(context.Background(), p)
+	_, err := exec.Run(context.Background(), p)
 	if err == nil {
 		t.Error("error expected")
 		return
@@ -112,31 +117,26 @@ func Test_executor_Run_ok(t *testing.T) {
 	totalCalls := 0
 	exec := executor{
 		DB: store,
-		RequesterFactory: func(req *http.Request) requester.Requester {
-			totalCalls++
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
 			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				totalCalls++
 				if totalCalls != c {
 					t.Errorf("unexpected number of calls. have %d want %d", totalCalls, c)
 				}
-				return bytes.NewBufferString("{}")
+				return bytes.NewBufferString(`{"NumRes":1}`)
 			})
 		},
 	}
-	req, err := http.NewRequest("GET", "/", nil)
-	if err != nil {
-		t.Errorf("building the request: %s", err.Error())
-		return
-	}
 	p := Plan{
 		Min:      1,
 		Max:      10,
 		Steps:    1,
 		Duration: 1,
-		Request:  req,
+		Request:  requester.Request{Method: "GET", URL: "/"},
 		Name:     name,
 	}
 
-	if _, err = exec.Run(context.Background(), p); err != nil {
+	if _, err := exec.Run(context.Background(), p); err != nil {
 		t.Errorf("unexpected error: %s", err.Error())
 		return
 	}
@@ -146,21 +146,285 @@ func Test_executor_Run_ok(t *testing.T) {
 		return
 	}
 
-	results := []requester.Report{}
-
-	if err = json.NewDecoder(r).Decode(&results); err != nil {
+	results, err := decodeResult(r)
+	if err != nil {
 		t.Error(err)
 		return
 	}
 
-	if len(results) != 9 {
-		t.Errorf("unexpected result size: %d", len(results))
+	if results.Status != ResultCompleted {
+		t.Errorf("unexpected result status: %s", results.Status)
+	}
+
+	if results.Version != ResultVersion {
+		t.Errorf("unexpected result version: %d", results.Version)
+	}
+
+	if results.Plan == nil || results.Plan.Request.URL != "/" || results.Plan.Max != 10 {
+		t.Errorf("unexpected plan: %+v", results.Plan)
+	}
+
+	if len(results.Reports) != 9 {
+		t.Errorf("unexpected result size: %d", len(results.Reports))
 	}
 
 }
 
+func Test_executor_Run_cancelled(t *testing.T) {
+	store := db.NewInMemory()
+	name := "some-name"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exec := executor{
+		DB: store,
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				if c == 4 {
+					cancel()
+				}
+				return bytes.NewBufferString(`{"NumRes":1}`)
+			})
+		},
+	}
+	p := Plan{
+		Min:      1,
+		Max:      10,
+		Steps:    1,
+		Duration: 1,
+		Request:  requester.Request{Method: "GET", URL: "/"},
+		Name:     name,
+	}
+
+	reports, err := exec.Run(ctx, p)
+	if err == nil {
+		t.Error("error expected")
+		return
+	}
+	if err.Error() != "executing the plan: executing the step #4 of the plan: context canceled" {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if len(reports) != 3 {
+		t.Errorf("unexpected number of reports: %d", len(reports))
+	}
+
+	r, err := store.Get(name)
+	if err != nil {
+		t.Errorf("accessing the store: %s", err.Error())
+		return
+	}
+	results, err := decodeResult(r)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if results.Status != ResultCancelled {
+		t.Errorf("unexpected result status: %s", results.Status)
+	}
+	if len(results.Reports) != 3 {
+		t.Errorf("unexpected result size: %d", len(results.Reports))
+	}
+}
+
 type dummyRequester func(ctx context.Context, c int) io.Reader
 
 func (d dummyRequester) Run(ctx context.Context, c int) io.Reader {
 	return d(ctx, c)
 }
+
+func Test_executor_Run_invalidatesCache(t *testing.T) {
+	exec := executor{
+		DB: db.NewInMemory(),
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				return bytes.NewBufferString(`{"NumRes":1}`)
+			})
+		},
+	}
+	p := Plan{Name: "cached", Min: 1, Max: 2, Steps: 1, Duration: 1, Request: requester.Request{Method: "GET", URL: "/"}}
+	mutex.Lock()
+	cache[p.Name] = gin.H{}
+	mutex.Unlock()
+
+	if _, err := exec.Run(context.Background(), p); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, ok := cache[p.Name]; ok {
+		t.Error("the cached test was not invalidated")
+	}
+}
+
+func Test_executor_Run_rateMode(t *testing.T) {
+	store := db.NewInMemory()
+	exec := executor{
+		DB: store,
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			t.Error("the concurrency requester should not be used")
+			return nil
+		},
+		RateRequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			return dummyRequester(func(ctx context.Context, rate int) io.Reader {
+				return bytes.NewBufferString(`{"Dropped":1}`)
+			})
+		},
+	}
+	p := Plan{
+		Name:     "some-name",
+		Mode:     ModeRate,
+		Min:      100,
+		Max:      400,
+		Steps:    100,
+		Duration: 1,
+		Request:  requester.Request{Method: "GET", URL: "/"},
+	}
+
+	reports, err := exec.Run(context.Background(), p)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(reports) != 3 {
+		t.Errorf("unexpected number of reports: %d", len(reports))
+		return
+	}
+	for i, r := range reports {
+		if r.Rate != 100*(i+1) || r.C != 0 || r.Dropped != 1 {
+			t.Errorf("unexpected report #%d: rate %d, C %d, dropped %d", i, r.Rate, r.C, r.Dropped)
+		}
+	}
+}
+
+func Test_executor_Run_rateModeInFlight(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	exec := executor{DB: db.NewInMemory(), RateRequesterFactory: requester.NewRateJSON}
+	p := Plan{
+		Name:     "some-name",
+		Mode:     ModeRate,
+		Schedule: ScheduleCustom,
+		Stages:   []Step{{Load: 10, Duration: 500 * time.Millisecond}},
+		Request:  requester.Request{Method: "GET", URL: srv.URL},
+	}
+
+	reports, err := exec.Run(context.Background(), p)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	// the requests still in flight at the end of the step are measured too
+	if len(reports) != 1 || reports[0].NumRes < 5 || len(reports[0].ErrorDist) > 0 {
+		t.Errorf("unexpected reports: %+v", reports)
+	}
+}
+
+func Test_executor_Run_mix(t *testing.T) {
+	mix := []requester.Request{
+		{Name: "list", Method: "GET", URL: "/items", Weight: 7},
+		{Name: "create", Method: "POST", URL: "/items", Weight: 3},
+	}
+	exec := executor{
+		DB: db.NewInMemory(),
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			t.Error("the single request factory should not be used")
+			return nil
+		},
+		WorkloadRequesterFactory: func(w requester.Workload, _ time.Duration) (requester.Requester, error) {
+			if len(w.Mix) != 2 || w.Mix[1].Name != "create" {
+				t.Errorf("unexpected mix: %+v", w.Mix)
+			}
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				return bytes.NewBufferString(`{"NumRes":10,"Breakdown":[{"Name":"list","NumRes":7},{"Name":"create","NumRes":3}]}`)
+			}), nil
+		},
+	}
+	p := Plan{
+		Name:     "some-name",
+		Min:      1,
+		Max:      3,
+		Steps:    1,
+		Duration: 1,
+		Request:  mix[0],
+		Mix:      mix,
+	}
+
+	reports, err := exec.Run(context.Background(), p)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(reports) != 2 {
+		t.Errorf("unexpected number of reports: %d", len(reports))
+		return
+	}
+	if len(reports[0].Breakdown) != 2 || reports[0].Breakdown[0].NumRes != 7 {
+		t.Errorf("unexpected breakdown: %+v", reports[0].Breakdown)
+	}
+}
+
+func Test_executor_Run_template(t *testing.T) {
+	exec := executor{
+		DB: db.NewInMemory(),
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			t.Error("the static request factory should not be used")
+			return nil
+		},
+		WorkloadRequesterFactory: func(w requester.Workload, _ time.Duration) (requester.Requester, error) {
+			if len(w.Mix) != 1 || w.Mix[0].Body != `{"id":"{{ uuid }}"}` {
+				t.Errorf("unexpected workload: %+v", w)
+			}
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				return bytes.NewBufferString(`{"NumRes":1}`)
+			}), nil
+		},
+	}
+	p := Plan{
+		Name:     "some-name",
+		Min:      1,
+		Max:      2,
+		Steps:    1,
+		Duration: 1,
+		Request:  requester.Request{Method: "POST", URL: "/", Body: `{"id":"{{ uuid }}"}`},
+	}
+
+	if _, err := exec.Run(context.Background(), p); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func Test_executor_Run_heyEngine(t *testing.T) {
+	exec := executor{
+		DB: db.NewInMemory(),
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			t.Error("the native requester should not be used")
+			return nil
+		},
+		HeyRequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				return bytes.NewBufferString(`{"NumRes":1}`)
+			})
+		},
+	}
+	p := Plan{
+		Name:     "some-name",
+		Engine:   EngineHey,
+		Min:      1,
+		Max:      3,
+		Steps:    1,
+		Duration: 1,
+		Request:  requester.Request{Method: "GET", URL: "/"},
+	}
+
+	reports, err := exec.Run(context.Background(), p)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(reports) != 2 {
+		t.Errorf("unexpected reports: %+v", reports)
+	}
+}
@@ -8,6 +8,7 @@ require (
 	github.com/aws/aws-sdk-go v1.55.6
 	github.com/gin-gonic/gin v1.9.1
 	github.com/rakyll/hey v0.1.4
+	go.etcd.io/bbolt v1.3.11
 )
 
 require (
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kpacha/load-test/db"
+)
+
+// indexKey is the key of the DB storing the index of the tests
+const indexKey = internalKeyPrefix + "index"
+
+// indexSyncInterval is how often the index is reconciled with the keys of the
+// DB, so the tests stored or removed by others sharing it show up
+const indexSyncInterval = time.Minute
+
+var (
+	indexMutex = new(sync.Mutex)
+	// unindexable are the keys failing to decode, skipped by the next syncs
+	unindexable = map[string]struct{}{}
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 200
+)
+
+// TestInfo describes a stored test without decoding its reports
+type TestInfo struct {
+	ID       string
+	Created  time.Time
+	Finished time.Time
+	Duration time.Duration
+	// Target is the host receiving the requests of the plan
+	Target string `json:",omitempty"`
+	URL    string `json:",omitempty"`
+	// Plan is the summary of the plan
+	Plan   string `json:",omitempty"`
+	Status ResultStatus
+	Tags   []string `json:",omitempty"`
+	Notes  string   `json:",omitempty"`
+	// Verdict is "passed" or "failed" when the test was checked against a
+	// baseline
+	Verdict string `json:",omitempty"`
+}
+
+func newTestInfo(id string, res Result, m db.Metadata) TestInfo {
+	info := TestInfo{
+		ID:       id,
+		Created:  res.Created,
+		Finished: res.Finished,
+		Status:   res.Status,
+		Tags:     m.Tags,
+		Notes:    m.Notes,
+	}
+	// the results stored before the timestamps use the ones of the steps
+	if n := len(res.Reports); n > 0 && info.Created.IsZero() {
+		info.Created = res.Reports[0].Start
+		if last := res.Reports[n-1]; !last.Start.IsZero() {
+			info.Finished = last.Start.Add(last.Total)
+		}
+	}
+	if !info.Created.IsZero() && info.Finished.After(info.Created) {
+		info.Duration = info.Finished.Sub(info.Created)
+	}
+	if res.Plan != nil {
+		info.URL = res.Plan.targetURL()
+		info.Target = res.Plan.target()
+		info.Plan = res.Plan.String()
+	}
+	if res.Regression != nil {
+		info.Verdict = "failed"
+		if res.Regression.Passed {
+			info.Verdict = "passed"
+		}
+	}
+	return info
+}
+
+// targetURL returns the URL of the first request of the plan
+func (e Plan) targetURL() string {
+	switch {
+	case len(e.Mix) > 0:
+		return e.Mix[0].URL
+	case len(e.Scenario) > 0:
+		return e.Scenario[0].URL
+	}
+	return e.Request.URL
+}
+
+// target returns the host of the first request of the plan
+func (e Plan) target() string {
+	raw := e.targetURL()
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	return u.Host
+}
+
+// IndexQuery selects, sorts and paginates the entries of the index
+type IndexQuery struct {
+	// Q matches the name or the target of the tests
+	Q string
+	// Name and Target match a part of the name and of the target URL of the
+	// tests, ignoring the case
+	Name   string
+	Target string
+	// Tags must all be set on the tests
+	Tags    []string
+	Status  ResultStatus
+	Verdict string
+	// From and To limit the creation time of the tests
+	From time.Time
+	To   time.Time
+	// Sort is one of created (default), finished, duration, name or target
+	Sort string
+	Desc bool
+	// Page starts at 1
+	Page    int
+	PerPage int
+}
+
+// URL returns the link to the query
+func (q IndexQuery) URL() string {
+	v := url.Values{}
+	for k, s := range map[string]string{"q": q.Q, "name": q.Name, "target": q.Target, "status": string(q.Status), "verdict": q.Verdict} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	for _, tag := range q.Tags {
+		v.Add("tag", tag)
+	}
+	if !q.From.IsZero() {
+		v.Set("from", q.From.Format(time.RFC3339Nano))
+	}
+	if !q.To.IsZero() {
+		v.Set("to", q.To.Format(time.RFC3339Nano))
+	}
+	if q.Sort != "" && q.Sort != "created" {
+		v.Set("sort", q.Sort)
+	}
+	if !q.Desc {
+		v.Set("order", "asc")
+	}
+	if q.Page > 1 {
+		v.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.PerPage > 0 && q.PerPage != defaultPageSize {
+		v.Set("per_page", strconv.Itoa(q.PerPage))
+	}
+	if len(v) == 0 {
+		return "/tests"
+	}
+	return "/tests?" + v.Encode()
+}
+
+// WithPage returns the query of another page
+func (q IndexQuery) WithPage(page int) IndexQuery {
+	q.Page = page
+	return q
+}
+
+// SortedBy returns the query sorted by the field, from the first page. The
+// order is reversed if the query is already sorted by the field.
+func (q IndexQuery) SortedBy(field string) IndexQuery {
+	if q.Sort == field || (q.Sort == "" && field == "created") {
+		q.Desc = !q.Desc
+	} else {
+		q.Sort, q.Desc = field, field == "created" || field == "finished" || field == "duration"
+	}
+	q.Page = 1
+	return q
+}
+
+type IndexPage struct {
+	Total   int
+	Page    int
+	PerPage int
+	Tests   []TestInfo
+}
+
+func (q IndexQuery) match(t TestInfo) bool {
+	switch {
+	case q.Status != "" && t.Status != q.Status:
+		return false
+	case q.Verdict != "" && t.Verdict != q.Verdict:
+		return false
+	case !q.From.IsZero() && t.Created.Before(q.From):
+		return false
+	case !q.To.IsZero() && t.Created.After(q.To):
+		return false
+	case !containsFold(t.ID, q.Name):
+		return false
+	case !containsFold(t.URL, q.Target) && !containsFold(t.Target, q.Target):
+		return false
+	case !containsFold(t.ID, q.Q) && !containsFold(t.URL, q.Q) && !containsFold(t.Target, q.Q):
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !hasTag(t.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (q IndexQuery) less(a, b TestInfo) bool {
+	switch q.Sort {
+	case "finished":
+		if !a.Finished.Equal(b.Finished) {
+			return a.Finished.Before(b.Finished)
+		}
+	case "duration":
+		if a.Duration != b.Duration {
+			return a.Duration < b.Duration
+		}
+	case "name":
+	case "target":
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+	default:
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+	}
+	return a.ID < b.ID
+}
+
+// filter returns the sorted entries of the index matching the query
+func (q IndexQuery) filter(index map[string]TestInfo) []TestInfo {
+	tests := []TestInfo{}
+	for _, t := range index {
+		if q.match(t) {
+			tests = append(tests, t)
+		}
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		if q.Desc {
+			return q.less(tests[j], tests[i])
+		}
+		return q.less(tests[i], tests[j])
+	})
+	return tests
+}
+
+// apply filters, sorts and paginates the entries of the index
+func (q IndexQuery) apply(index map[string]TestInfo) IndexPage {
+	if q.PerPage <= 0 {
+		q.PerPage = defaultPageSize
+	}
+	if q.PerPage > maxPageSize {
+		q.PerPage = maxPageSize
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+
+	tests := q.filter(index)
+	page := IndexPage{Total: len(tests), Page: q.Page, PerPage: q.PerPage, Tests: []TestInfo{}}
+	if from := (q.Page - 1) * q.PerPage; from < len(tests) {
+		page.Tests = tests[from:min(from+q.PerPage, len(tests))]
+	}
+	return page
+}
+
+// Pages returns the number of pages of the query
+func (p IndexPage) Pages() int {
+	if p.PerPage <= 0 {
+		return 0
+	}
+	return (p.Total + p.PerPage - 1) / p.PerPage
+}
+
+// loadIndex returns the index stored in the DB, by test. The index is built
+// from the stored tests the first time.
+func loadIndex(store db.DB) (map[string]TestInfo, error) {
+	res := map[string]TestInfo{}
+	r, err := store.Get(indexKey)
+	switch err {
+	case db.ErrNotFound:
+		if _, err := reconcileIndex(store, res); err != nil {
+			return nil, err
+		}
+		return res, saveIndex(store, res)
+	case nil:
+	default:
+		return nil, err
+	}
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decoding the index: %s", err.Error())
+	}
+	return res, nil
+}
+
+// syncIndex reconciles the stored index with the keys of the DB, so the tests
+// stored or removed by others sharing it (or missed by a stale index) show up.
+// The keys failing to decode are retried if retry is set.
+func syncIndex(store db.DB, retry bool) error {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+
+	if retry {
+		unindexable = map[string]struct{}{}
+	}
+	index, err := loadIndex(store)
+	if err != nil {
+		return err
+	}
+	changed, err := reconcileIndex(store, index)
+	if err != nil || !changed {
+		return err
+	}
+	return saveIndex(store, index)
+}
+
+// reconcileIndex indexes the stored tests missing in the index and drops the
+// entries without a stored test. It reports whether the index changed. It must
+// be called with the index lock held.
+func reconcileIndex(store db.DB, index map[string]TestInfo) (bool, error) {
+	keys, err := store.Keys()
+	if err != nil {
+		return false, err
+	}
+	stored := map[string]struct{}{}
+	added := 0
+	for _, k := range keys {
+		if isInternalKey(k) {
+			continue
+		}
+		stored[k] = struct{}{}
+		if _, ok := index[k]; ok {
+			continue
+		}
+		if _, ok := unindexable[k]; ok {
+			continue
+		}
+		r, err := store.Get(k)
+		if err != nil {
+			log.Printf("indexing %s: %s", k, err.Error())
+			continue
+		}
+		result, err := decodeResult(r)
+		if err != nil {
+			log.Printf("indexing %s: %s", k, err.Error())
+			unindexable[k] = struct{}{}
+			continue
+		}
+		m, _ := store.Meta(k)
+		index[k] = newTestInfo(k, result, m)
+		added++
+	}
+	removed := 0
+	for k := range index {
+		if _, ok := stored[k]; !ok {
+			delete(index, k)
+			removed++
+		}
+	}
+	if added+removed > 0 {
+		log.Printf("index reconciled: %d tests added, %d removed", added, removed)
+	}
+	return added+removed > 0, nil
+}
+
+func saveIndex(store db.DB, index map[string]TestInfo) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("encoding the index: %s", err.Error())
+	}
+	if _, err := store.Set(indexKey, bytes.NewBuffer(data)); err != nil {
+		return fmt.Errorf("storing the index: %s", err.Error())
+	}
+	return nil
+}
+
+// updateIndex applies the changes to the stored index
+func updateIndex(store db.DB, update func(map[string]TestInfo)) error {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+
+	index, err := loadIndex(store)
+	if err != nil {
+		return err
+	}
+	update(index)
+	return saveIndex(store, index)
+}
+
+func readIndex(store db.DB) (map[string]TestInfo, error) {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	return loadIndex(store)
+}
+
+// queryIndex returns the page of the index selected by the query
+func queryIndex(store db.DB, q IndexQuery) (IndexPage, error) {
+	index, err := readIndex(store)
+	if err != nil {
+		return IndexPage{}, err
+	}
+	return q.apply(index), nil
+}
+
+// indexTest adds the stored result to the index. The result replaces any
+// previous test with the same id, so the tags of its plan become the tags of
+// the test and the notes of the replaced one are dropped.
+func indexTest(store db.DB, id string, res Result) error {
+	old, err := store.Meta(id)
+	if err != nil {
+		return err
+	}
+	m := db.Metadata{Tags: res.Plan.Tags}
+	if len(old.Tags) > 0 || old.Notes != "" || len(m.Tags) > 0 {
+		if err := store.SetMeta(id, m); err != nil {
+			return err
+		}
+	}
+	info := newTestInfo(id, res, m)
+	return updateIndex(store, func(index map[string]TestInfo) {
+		index[id] = info
+	})
+}
+
+// indexIDs returns the ids of the tests, newest first
+func indexIDs(index map[string]TestInfo) []string {
+	tests := IndexQuery{Desc: true}.filter(index)
+	ids := make([]string, len(tests))
+	for i, t := range tests {
+		ids[i] = t.ID
+	}
+	return ids
+}
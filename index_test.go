@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kpacha/load-test/db"
+	"github.com/kpacha/load-test/requester"
+)
+
+func Test_newTestInfo(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	first, last := requester.Report{Start: start}, requester.Report{Start: start.Add(time.Minute)}
+	last.Total = 30 * time.Second
+	res := Result{
+		Plan:       &Plan{Name: "a", Min: 1, Max: 2, Steps: 2, Request: requester.Request{URL: "http://example.com:8080/foo"}},
+		Status:     ResultStopped,
+		Reports:    []requester.Report{first, last},
+		Regression: &Verdict{Passed: false},
+	}
+
+	info := newTestInfo("a", res, db.Metadata{Tags: []string{"x"}, Notes: "n"})
+	if !info.Created.Equal(start) || info.Duration != 90*time.Second {
+		t.Errorf("unexpected times: %s %s", info.Created, info.Duration)
+	}
+	if info.Target != "example.com:8080" || info.Plan != res.Plan.String() {
+		t.Errorf("unexpected plan: %s %s", info.Target, info.Plan)
+	}
+	if info.Status != ResultStopped || info.Verdict != "failed" || info.Tags[0] != "x" || info.Notes != "n" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	res.Created, res.Finished = start.Add(-time.Second), start.Add(time.Hour)
+	res.Regression.Passed = true
+	info = newTestInfo("a", res, db.Metadata{})
+	if !info.Created.Equal(res.Created) || info.Duration != time.Hour+time.Second || info.Verdict != "passed" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func TestIndexQuery_apply(t *testing.T) {
+	now := time.Now()
+	index := map[string]TestInfo{}
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		index[id] = TestInfo{
+			ID:       id,
+			Created:  now.Add(time.Duration(i) * time.Minute),
+			Duration: time.Duration(5-i) * time.Second,
+			Status:   ResultCompleted,
+		}
+	}
+	index["b"] = TestInfo{ID: "b", Created: now.Add(time.Minute), Status: ResultCancelled, Tags: []string{"x"}}
+	index["c"] = TestInfo{ID: "c", Created: now.Add(2 * time.Minute), Duration: 3 * time.Second, Status: ResultCompleted, Tags: []string{"x", "y"},
+		Target: "api.example.com", URL: "http://api.example.com/Users"}
+	index["d"] = TestInfo{ID: "d", Created: now.Add(3 * time.Minute), Duration: 2 * time.Second, Status: ResultCompleted, Verdict: "failed",
+		Target: "web.example.com", URL: "http://web.example.com/"}
+
+	for _, tc := range []struct {
+		q     IndexQuery
+		total int
+		ids   []string
+	}{
+		{IndexQuery{Desc: true}, 5, []string{"e", "d", "c", "b", "a"}},
+		{IndexQuery{Desc: true, PerPage: 2, Page: 2}, 5, []string{"c", "b"}},
+		{IndexQuery{PerPage: 2, Page: 3}, 5, []string{"e"}},
+		{IndexQuery{PerPage: 2, Page: 4}, 5, []string{}},
+		{IndexQuery{Sort: "duration"}, 5, []string{"b", "e", "d", "c", "a"}},
+		{IndexQuery{Sort: "name", Desc: true, PerPage: 1}, 5, []string{"e"}},
+		{IndexQuery{Tags: []string{"x"}}, 2, []string{"b", "c"}},
+		{IndexQuery{Status: ResultCompleted, Desc: true, PerPage: 1}, 4, []string{"e"}},
+		{IndexQuery{Verdict: "passed"}, 0, []string{}},
+		{IndexQuery{Verdict: "failed"}, 1, []string{"d"}},
+		{IndexQuery{Tags: []string{"x", "y"}}, 1, []string{"c"}},
+		{IndexQuery{Name: "C"}, 1, []string{"c"}},
+		{IndexQuery{Target: "/users"}, 1, []string{"c"}},
+		{IndexQuery{Target: "example.com"}, 2, []string{"c", "d"}},
+		{IndexQuery{Q: "web"}, 1, []string{"d"}},
+		{IndexQuery{Q: "e"}, 3, []string{"c", "d", "e"}},
+		{IndexQuery{From: now.Add(90 * time.Second), To: now.Add(3 * time.Minute)}, 2, []string{"c", "d"}},
+	} {
+		page := tc.q.apply(index)
+		if page.Total != tc.total || len(page.Tests) != len(tc.ids) {
+			t.Errorf("unexpected page for %+v: %+v", tc.q, page)
+			continue
+		}
+		for i, id := range tc.ids {
+			if page.Tests[i].ID != id {
+				t.Errorf("unexpected test #%d for %+v: %s", i, tc.q, page.Tests[i].ID)
+			}
+		}
+	}
+}
+
+func TestIndexQuery_URL(t *testing.T) {
+	q := IndexQuery{Tags: []string{"x"}, Sort: "created", Desc: true, Page: 1, PerPage: defaultPageSize}
+	if u := q.URL(); u != "/tests?tag=x" {
+		t.Errorf("unexpected url: %s", u)
+	}
+	if u := q.SortedBy("created").WithPage(2).URL(); u != "/tests?order=asc&page=2&tag=x" {
+		t.Errorf("unexpected url: %s", u)
+	}
+	if u := q.SortedBy("name").URL(); u != "/tests?order=asc&sort=name&tag=x" {
+		t.Errorf("unexpected url: %s", u)
+	}
+}
+
+func Test_indexTest(t *testing.T) {
+	store := db.NewInMemory()
+	data, _ := encodeResult(Result{Plan: &Plan{Name: "old"}, Status: ResultCompleted, Reports: []requester.Report{{C: 1}}})
+	store.Set("old", data)
+
+	res := Result{Plan: &Plan{Name: "new", Tags: []string{"nightly"}}, Status: ResultCompleted, Created: time.Now()}
+	data, _ = encodeResult(res)
+	store.Set("new", data)
+	if err := indexTest(store, "new", res); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the stored tests are indexed the first time
+	page, err := queryIndex(store, IndexQuery{Desc: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if page.Total != 2 || page.Tests[0].ID != "new" || page.Tests[1].ID != "old" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+	if m, _ := store.Meta("new"); len(m.Tags) != 1 || m.Tags[0] != "nightly" {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+	if page, _ := queryIndex(store, IndexQuery{Tags: []string{"nightly"}}); page.Total != 1 {
+		t.Errorf("unexpected page: %+v", page)
+	}
+
+	// a new run with the same name replaces the tags and the notes
+	store.SetMeta("new", db.Metadata{Tags: []string{"nightly", "manual"}, Notes: "flaky"})
+	res.Plan.Tags = []string{"weekly"}
+	data, _ = encodeResult(res)
+	store.Set("new", data)
+	if err := indexTest(store, "new", res); err != nil {
+		t.Error(err)
+		return
+	}
+	if m, _ := store.Meta("new"); len(m.Tags) != 1 || m.Tags[0] != "weekly" || m.Notes != "" {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+	if page, _ := queryIndex(store, IndexQuery{Tags: []string{"weekly"}}); page.Total != 1 || page.Tests[0].Notes != "" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+}
+
+func Test_syncIndex(t *testing.T) {
+	store := db.NewInMemory()
+	now := time.Now()
+	for i, id := range []string{"a", "b", "c"} {
+		data, _ := encodeResult(Result{Plan: &Plan{Name: id}, Status: ResultCompleted, Reports: []requester.Report{{C: 1}},
+			Created: now.Add(time.Duration(i) * time.Minute)})
+		store.Set(id, data)
+	}
+	c, _ := store.Get("c")
+	store.Delete("c")
+	if _, err := readIndex(store); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// another instance sharing the store adds a test and removes other one
+	// without updating the index seen here
+	store.Set("c", c)
+	store.Delete("a")
+	store.Set("broken", bytes.NewBufferString("{"))
+
+	// the index is only reconciled on sync
+	if index, _ := readIndex(store); len(index) != 2 || index["a"].ID != "a" {
+		t.Errorf("unexpected index: %+v", index)
+	}
+	if err := syncIndex(store, false); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := unindexable["broken"]; !ok {
+		t.Error("the broken test should be skipped by the next syncs")
+	}
+
+	index, err := readIndex(store)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if ids := indexIDs(index); len(ids) != 2 || ids[0] != "c" || ids[1] != "b" {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+func (s JobStatus) Done() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobNotQueued = errors.New("job not queued")
+	ErrJobFinished  = errors.New("job already finished")
+)
+
+const (
+	// finishedJobTTL is how long a finished job is kept in the registry
+	finishedJobTTL = 24 * time.Hour
+	// maxFinishedJobs is the number of finished jobs kept in the registry
+	maxFinishedJobs = 100
+)
+
+type Job struct {
+	ID         string
+	Name       string
+	Plan       string
+	Priority   int
+	Position   int `json:",omitempty"`
+	Status     JobStatus
+	Error      string `json:",omitempty"`
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+type JobRegistry interface {
+	Submit(plan Plan) Job
+	Get(id string) (Job, error)
+	List() []Job
+	Remove(id string) error
+	Cancel(id string) error
+}
+
+// NewJobRegistry returns a JobRegistry running up to parallelism plans at once.
+// The rest of the submitted plans wait in a priority queue.
+func NewJobRegistry(ctx context.Context, executor Executor, parallelism int) JobRegistry {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return &jobRegistry{
+		ctx:         ctx,
+		executor:    executor,
+		parallelism: parallelism,
+		jobs:        map[string]*Job{},
+		queue:       &planQueue{},
+		cancels:     map[string]context.CancelFunc{},
+	}
+}
+
+type jobRegistry struct {
+	ctx         context.Context
+	executor    Executor
+	parallelism int
+	mu          sync.RWMutex
+	jobs        map[string]*Job
+	queue       *planQueue
+	cancels     map[string]context.CancelFunc
+	running     int
+}
+
+func (r *jobRegistry) Submit(plan Plan) Job {
+	job := &Job{
+		ID:        newJobID(),
+		Name:      plan.Name,
+		Plan:      plan.String(),
+		Priority:  plan.Priority,
+		Status:    JobQueued,
+		CreatedAt: time.Now(),
+	}
+
+	plan.ID = job.ID
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.jobs[job.ID] = job
+	r.queue.Push(job.ID, plan)
+	r.dispatch()
+
+	return r.snapshot(job)
+}
+
+func (r *jobRegistry) Get(id string) (Job, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return r.snapshot(job), nil
+}
+
+func (r *jobRegistry) List() []Job {
+	r.mu.RLock()
+	res := make([]Job, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		res = append(res, r.snapshot(job))
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CreatedAt.After(res[j].CreatedAt)
+	})
+	return res
+}
+
+func (r *jobRegistry) Remove(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if !r.queue.Remove(id) {
+		return ErrJobNotQueued
+	}
+	job.Status = JobCancelled
+	job.FinishedAt = time.Now()
+	log.Printf("job %s removed from the queue", id)
+	r.prune(job.FinishedAt)
+	return nil
+}
+
+// Cancel removes the job from the queue or, if it is already running, cancels
+// its context so the executor stops it and stores the steps completed so far.
+func (r *jobRegistry) Cancel(id string) error {
+	if err := r.Remove(id); err != ErrJobNotQueued {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cancel, ok := r.cancels[id]
+	if !ok {
+		return ErrJobFinished
+	}
+	log.Printf("cancelling the job %s", id)
+	cancel()
+	return nil
+}
+
+// dispatch starts as many queued plans as free slots are available. It must be
+// called with the lock held.
+func (r *jobRegistry) dispatch() {
+	for r.running < r.parallelism {
+		next, ok := r.queue.Pop()
+		if !ok {
+			return
+		}
+		job := r.jobs[next.ID]
+		job.Status = JobRunning
+		job.StartedAt = time.Now()
+		r.running++
+		ctx, cancel := context.WithCancel(r.ctx)
+		r.cancels[next.ID] = cancel
+		go r.run(ctx, next.ID, next.Plan)
+	}
+}
+
+func (r *jobRegistry) snapshot(job *Job) Job {
+	res := *job
+	if res.Status == JobQueued {
+		res.Position = r.queue.Position(job.ID)
+	}
+	return res
+}
+
+func (r *jobRegistry) run(ctx context.Context, id string, plan Plan) {
+	log.Printf("starting the job %s (%s)", id, plan.Name)
+	_, err := r.executor.Run(ctx, plan)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running--
+	defer r.dispatch()
+
+	job := r.jobs[id]
+	job.FinishedAt = time.Now()
+	switch {
+	case err == nil:
+		job.Status = JobSucceeded
+	case ctx.Err() != nil:
+		job.Status = JobCancelled
+		job.Error = err.Error()
+	default:
+		job.Status = JobFailed
+		job.Error = err.Error()
+	}
+	r.cancels[id]()
+	delete(r.cancels, id)
+	log.Printf("job %s finished with status %s", id, job.Status)
+	r.prune(job.FinishedAt)
+}
+
+// prune drops the jobs finished more than finishedJobTTL ago and the oldest
+// finished ones above maxFinishedJobs. It must be called with the lock held.
+func (r *jobRegistry) prune(now time.Time) {
+	finished := []*Job{}
+	for id, job := range r.jobs {
+		switch {
+		case !job.Status.Done():
+		case now.Sub(job.FinishedAt) > finishedJobTTL:
+			delete(r.jobs, id)
+		default:
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(finished[j].FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(r.jobs, job.ID)
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+func TestNewJobRegistry_queue(t *testing.T) {
+	release := make(chan struct{})
+	mu := new(sync.Mutex)
+	executed := []string{}
+	exec := dummyExecutor(func(_ context.Context, p Plan) ([]requester.Report, error) {
+		<-release
+		mu.Lock()
+		executed = append(executed, p.Name)
+		mu.Unlock()
+		return []requester.Report{}, nil
+	})
+	jobs := NewJobRegistry(context.Background(), exec, 1)
+
+	first := jobs.Submit(Plan{Name: "first"})
+	if first.Status != JobRunning {
+		t.Errorf("unexpected status: %s", first.Status)
+	}
+	low := jobs.Submit(Plan{Name: "low"})
+	removed := jobs.Submit(Plan{Name: "removed"})
+	high := jobs.Submit(Plan{Name: "high", Priority: 10})
+
+	for id, position := range map[string]int{
+		high.ID:    1,
+		low.ID:     2,
+		removed.ID: 3,
+	} {
+		job, err := jobs.Get(id)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if job.Status != JobQueued {
+			t.Errorf("unexpected status for %s: %s", job.Name, job.Status)
+		}
+		if job.Position != position {
+			t.Errorf("unexpected position for %s: %d", job.Name, job.Position)
+		}
+	}
+
+	if err := jobs.Remove(removed.ID); err != nil {
+		t.Error(err)
+	}
+	if err := jobs.Remove(removed.ID); err != ErrJobNotQueued {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := jobs.Remove(first.ID); err != ErrJobNotQueued {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := jobs.Remove("unknown"); err != ErrJobNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	close(release)
+
+	for _, id := range []string{first.ID, high.ID, low.ID} {
+		if job := waitForJob(t, jobs, id); job.Status != JobSucceeded {
+			t.Errorf("unexpected status for %s: %s", job.Name, job.Status)
+		}
+	}
+	if job, _ := jobs.Get(removed.ID); job.Status != JobCancelled {
+		t.Errorf("unexpected status for the removed job: %s", job.Status)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(executed) != 3 || executed[0] != "first" || executed[1] != "high" || executed[2] != "low" {
+		t.Errorf("unexpected execution order: %v", executed)
+	}
+}
+
+func TestNewJobRegistry_parallelism(t *testing.T) {
+	release := make(chan struct{})
+	exec := dummyExecutor(func(_ context.Context, p Plan) ([]requester.Report, error) {
+		<-release
+		return []requester.Report{}, nil
+	})
+	jobs := NewJobRegistry(context.Background(), exec, 2)
+
+	submitted := []Job{
+		jobs.Submit(Plan{Name: "a"}),
+		jobs.Submit(Plan{Name: "b"}),
+		jobs.Submit(Plan{Name: "c"}),
+	}
+	for i, status := range []JobStatus{JobRunning, JobRunning, JobQueued} {
+		if submitted[i].Status != status {
+			t.Errorf("unexpected status for %s: %s", submitted[i].Name, submitted[i].Status)
+		}
+	}
+
+	close(release)
+
+	for _, job := range submitted {
+		waitForJob(t, jobs, job.ID)
+	}
+}
+
+func TestNewJobRegistry_cancel(t *testing.T) {
+	started := make(chan struct{})
+	exec := dummyExecutor(func(ctx context.Context, p Plan) ([]requester.Report, error) {
+		close(started)
+		<-ctx.Done()
+		return []requester.Report{}, ctx.Err()
+	})
+	jobs := NewJobRegistry(context.Background(), exec, 1)
+
+	running := jobs.Submit(Plan{Name: "running"})
+	queued := jobs.Submit(Plan{Name: "queued"})
+
+	<-started
+
+	if err := jobs.Cancel(queued.ID); err != nil {
+		t.Error(err)
+	}
+	if err := jobs.Cancel(running.ID); err != nil {
+		t.Error(err)
+	}
+
+	for _, id := range []string{running.ID, queued.ID} {
+		if job := waitForJob(t, jobs, id); job.Status != JobCancelled {
+			t.Errorf("unexpected status for %s: %s", job.Name, job.Status)
+		}
+	}
+
+	if err := jobs.Cancel(running.ID); err != ErrJobFinished {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := jobs.Cancel("unknown"); err != ErrJobNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestJobRegistry_prune(t *testing.T) {
+	now := time.Now()
+	r := NewJobRegistry(context.Background(), nil, 1).(*jobRegistry)
+	r.jobs["queued"] = &Job{ID: "queued", Status: JobQueued, CreatedAt: now.Add(-2 * finishedJobTTL)}
+	r.jobs["expired"] = &Job{ID: "expired", Status: JobSucceeded, FinishedAt: now.Add(-2 * finishedJobTTL)}
+	for i := 0; i <= maxFinishedJobs; i++ {
+		id := fmt.Sprintf("job-%d", i)
+		r.jobs[id] = &Job{ID: id, Status: JobFailed, FinishedAt: now.Add(time.Duration(i) * time.Second)}
+	}
+
+	r.prune(now)
+	if len(r.jobs) != maxFinishedJobs+1 {
+		t.Errorf("unexpected number of jobs: %d", len(r.jobs))
+	}
+	for _, id := range []string{"expired", "job-0"} {
+		if _, ok := r.jobs[id]; ok {
+			t.Errorf("the job %s was not pruned", id)
+		}
+	}
+	if _, ok := r.jobs["queued"]; !ok {
+		t.Error("the queued job was pruned")
+	}
+}
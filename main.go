@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -16,8 +17,11 @@ import (
 )
 
 //go:embed templates/browse.html
+//go:embed templates/compare.html
 //go:embed templates/index.html
+//go:embed templates/job.html
 //go:embed templates/partials.html
+//go:embed templates/tests.html
 var fs embed.FS
 
 func main() {
@@ -25,13 +29,35 @@ func main() {
 	port := flag.Int("p", 7879, "port to expose the html ui")
 	isDevel := flag.Bool("d", false, "devel mode enabled")
 	inMemory := flag.Bool("m", false, "use in-memory store instead of the fs persistent one")
+	boltPath := flag.String("b", "", "path to the embedded database file to use as store instead of the fs one")
+	useS3 := flag.Bool("s3", false, "use the S3_BUCKET bucket as store, caching its contents in the "+s3CacheDir+" dir of the -f path")
+	migrate := flag.Bool("migrate", false, "import the tests of the -f path into the -b database or the -s3 bucket and exit")
+	parallelism := flag.Int("c", 1, "number of plans allowed to run at once")
 	flag.Parse()
 
+	if *migrate && *boltPath == "" && !*useS3 {
+		log.Fatal("the migration requires the -b or the -s3 flag")
+	}
+
 	var store db.DB
-	if *inMemory {
+	switch {
+	case *inMemory:
 		store = db.NewInMemory()
-	} else {
-		s, err := session.NewSession(&aws.Config{Region: aws.String(os.Getenv("S3_REGION"))})
+	case *boltPath != "":
+		b, err := db.NewBolt(*boltPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer b.Close()
+		store = b
+	case *useS3:
+		s, err := newS3Store(filepath.Join(*storePath, s3CacheDir))
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = s
+	default:
+		s, err := s3Session()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -41,7 +67,16 @@ func main() {
 		}
 	}
 
-	quit := make(chan os.Signal)
+	if *migrate {
+		n, err := importTests(store, db.NewLocalFS(*storePath))
+		if err != nil {
+			log.Fatalf("migrating the store: %s", err.Error())
+		}
+		log.Printf("%d keys imported from %s", n, *storePath)
+		return
+	}
+
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -52,7 +87,10 @@ func main() {
 		cancel()
 	}()
 
-	server, err := NewServer(gin.Default(), store, NewExecutor(store), *isDevel)
+	events := NewBroker()
+	jobs := NewJobRegistry(ctx, NewExecutor(store, events), *parallelism)
+
+	server, err := NewServer(gin.Default(), store, jobs, events, *isDevel)
 	if err != nil {
 		fmt.Println("error building the server:", err.Error())
 		return
@@ -60,3 +98,80 @@ func main() {
 
 	fmt.Println(server.Run(ctx, fmt.Sprintf(":%d", *port)))
 }
+
+// s3Session returns a session for the S3_REGION region. S3_ENDPOINT points it
+// to a S3-compatible server instead of AWS.
+func s3Session() (*session.Session, error) {
+	cfg := &aws.Config{Region: aws.String(os.Getenv("S3_REGION"))}
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+		cfg.Endpoint = aws.String(endpoint)
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	return session.NewSession(cfg)
+}
+
+// s3CacheDir is the dir of the -f path caching the contents of the S3 store.
+// It is kept apart from the tests stored in the -f path, so they can be
+// migrated to the bucket without being overwritten by the cache.
+const s3CacheDir = ".s3-cache"
+
+// newS3Store returns the store of the S3_BUCKET bucket, keeping the tests
+// under the S3_PREFIX prefix ("load-test" by default)
+func newS3Store(cachePath string) (db.DB, error) {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("the S3 store requires the S3_BUCKET env var")
+	}
+	prefix := os.Getenv("S3_PREFIX")
+	if prefix == "" {
+		prefix = "load-test"
+	}
+	if err := os.MkdirAll(cachePath, 0755); err != nil {
+		return nil, fmt.Errorf("creating the cache dir: %s", err.Error())
+	}
+	s, err := s3Session()
+	if err != nil {
+		return nil, err
+	}
+	return db.NewS3Store(s, bucket, prefix, db.NewLocalFS(cachePath)), nil
+}
+
+// importTests copies the tests of the src store into the dst one. The
+// baselines of the src store are added to the dst ones, keeping the dst
+// baseline of every scope defined in both, and the index is rebuilt.
+func importTests(dst, src db.DB) (int, error) {
+	n, err := db.Copy(dst, src, isInternalKey)
+	if err != nil {
+		return n, err
+	}
+	if err := mergeBaselines(dst, src); err != nil {
+		return n, err
+	}
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	if err := dst.Delete(indexKey); err != nil && err != db.ErrNotFound {
+		return n, err
+	}
+	_, err = loadIndex(dst)
+	return n, err
+}
+
+func mergeBaselines(dst, src db.DB) error {
+	baselinesMutex.Lock()
+	defer baselinesMutex.Unlock()
+
+	imported, err := loadBaselines(src)
+	if err != nil || len(imported) == 0 {
+		return err
+	}
+	baselines, err := loadBaselines(dst)
+	if err != nil {
+		return err
+	}
+	for scope, b := range imported {
+		if _, ok := baselines[scope]; !ok {
+			baselines[scope] = b
+		}
+	}
+	return saveBaselines(dst, baselines)
+}
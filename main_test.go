@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kpacha/load-test/db"
+	"github.com/kpacha/load-test/requester"
+)
+
+func Test_importTests(t *testing.T) {
+	src, dst := db.NewInMemory(), db.NewInMemory()
+	for _, store := range []db.DB{src, dst} {
+		data, _ := encodeResult(Result{Plan: &Plan{Name: "a"}, Status: ResultCompleted, Reports: []requester.Report{{C: 1}}, Created: time.Now()})
+		store.Set("a", data)
+	}
+	data, _ := encodeResult(Result{Plan: &Plan{Name: "b"}, Status: ResultCompleted, Reports: []requester.Report{{C: 1}}, Created: time.Now()})
+	src.Set("b", data)
+	setBaseline(src, Baseline{Scope: nameScope("a"), ID: "src"})
+	setBaseline(src, Baseline{Scope: nameScope("b"), ID: "src"})
+	setBaseline(dst, Baseline{Scope: nameScope("a"), ID: "dst"})
+	if _, err := readIndex(dst); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if n, err := importTests(dst, src); err != nil || n != 2 {
+		t.Errorf("unexpected result: %d %v", n, err)
+		return
+	}
+
+	baselines, err := loadBaselines(dst)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(baselines) != 2 || baselines[nameScope("a")].ID != "dst" || baselines[nameScope("b")].ID != "src" {
+		t.Errorf("unexpected baselines: %+v", baselines)
+	}
+	index, err := readIndex(dst)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(index) != 2 || index["b"].ID != "b" {
+		t.Errorf("unexpected index: %+v", index)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+// defaultPercentiles are the percentiles displayed when none is requested
+var defaultPercentiles = []float64{50, 90, 99, 99.9, 99.99}
+
+// PercentileReport holds the latencies (in seconds) of the requested
+// percentiles for every step and for the whole plan. The corrected ones are
+// only set if the latencies were corrected for coordinated omission.
+type PercentileReport struct {
+	Percentiles  []float64
+	Steps        []StepPercentiles
+	All          []float64
+	AllCorrected []float64 `json:",omitempty"`
+}
+
+type StepPercentiles struct {
+	C         int
+	Rate      int `json:",omitempty"`
+	Latencies []float64
+	Corrected []float64 `json:",omitempty"`
+}
+
+func newPercentileReport(reports []requester.Report, percentiles []float64) PercentileReport {
+	res := PercentileReport{
+		Percentiles: percentiles,
+		Steps:       make([]StepPercentiles, len(reports)),
+		All:         make([]float64, len(percentiles)),
+	}
+	all := mergeLatencies(reports)
+	for i, p := range percentiles {
+		res.All[i] = all.Percentile(p).Seconds()
+	}
+	if corrected := mergeCorrected(reports); corrected.Count() > 0 {
+		res.AllCorrected = make([]float64, len(percentiles))
+		for i, p := range percentiles {
+			res.AllCorrected[i] = corrected.Percentile(p).Seconds()
+		}
+	}
+	for i := range reports {
+		r := &reports[i]
+		step := StepPercentiles{C: r.C, Rate: r.Rate, Latencies: make([]float64, len(percentiles))}
+		for j, p := range percentiles {
+			step.Latencies[j] = r.Percentile(p)
+		}
+		if r.Corrected.Count() > 0 {
+			step.Corrected = make([]float64, len(percentiles))
+			for j, p := range percentiles {
+				step.Corrected[j] = r.CorrectedPercentile(p)
+			}
+		}
+		res.Steps[i] = step
+	}
+	return res
+}
+
+// mergeLatencies returns the histogram of the latencies of all the steps
+func mergeLatencies(reports []requester.Report) *requester.Histogram {
+	h := requester.NewHistogram()
+	for i := range reports {
+		h.Merge(reports[i].LatencyHistogram())
+	}
+	return h
+}
+
+// mergeCorrected returns the histogram of the corrected latencies of all the
+// steps
+func mergeCorrected(reports []requester.Report) *requester.Histogram {
+	h := requester.NewHistogram()
+	for _, r := range reports {
+		h.Merge(r.Corrected)
+	}
+	return h
+}
+
+// parsePercentiles parses a list of percentiles, as repeated or comma
+// separated values, ignoring the ones out of the (0, 100] range. It returns
+// the default ones if the list is empty.
+func parsePercentiles(values []string) []float64 {
+	res := []float64{}
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			p, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || p <= 0 || p > 100 {
+				continue
+			}
+			res = append(res, p)
+		}
+	}
+	if len(res) == 0 {
+		return defaultPercentiles
+	}
+	return res
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+func Test_parsePercentiles(t *testing.T) {
+	for _, tc := range []struct {
+		values   []string
+		expected []float64
+	}{
+		{nil, defaultPercentiles},
+		{[]string{""}, defaultPercentiles},
+		{[]string{"99.9,99.99", "50"}, []float64{99.9, 99.99, 50}},
+		{[]string{"0, 101, x, 100"}, []float64{100}},
+	} {
+		if res := parsePercentiles(tc.values); !reflect.DeepEqual(res, tc.expected) {
+			t.Errorf("unexpected percentiles for %v: %v", tc.values, res)
+		}
+	}
+}
+
+func Test_newPercentileReport(t *testing.T) {
+	native := requester.NewHistogram()
+	for i := 0; i < 999; i++ {
+		native.Record(time.Millisecond)
+	}
+	native.Record(time.Second)
+	reports := []requester.Report{
+		{C: 1, Latencies: native},
+		// the reports of hey keep the raw latencies
+		{C: 2},
+	}
+	reports[1].Lats = []float64{0.001, 0.002, 2}
+
+	res := newPercentileReport(reports, []float64{50, 99.95})
+	if len(res.Steps) != 2 || res.Steps[1].C != 2 {
+		t.Errorf("unexpected steps: %+v", res.Steps)
+		return
+	}
+	if l := res.Steps[0].Latencies; l[0] < 0.001 || l[0] > 0.001001 || l[1] != 1 {
+		t.Errorf("unexpected latencies of the first step: %v", l)
+	}
+	if l := res.Steps[1].Latencies; l[0] != 0.002 || l[1] != 2 {
+		t.Errorf("unexpected latencies of the second step: %v", l)
+	}
+	if l := res.All; l[0] < 0.001 || l[0] > 0.001001 || l[1] != 2 {
+		t.Errorf("unexpected latencies of the plan: %v", l)
+	}
+	if res.AllCorrected != nil || res.Steps[0].Corrected != nil {
+		t.Errorf("the latencies were not corrected: %+v", res)
+	}
+
+	reports[1].Correct(500 * time.Millisecond)
+	res = newPercentileReport(reports, []float64{50, 99.95})
+	if res.Steps[0].Corrected != nil || len(res.Steps[1].Corrected) != 2 || len(res.AllCorrected) != 2 {
+		t.Errorf("unexpected corrected latencies: %+v", res)
+	}
+	// 2s, plus the missing 1.5s, 1s and 0.5s
+	if l := res.Steps[1].Corrected; l[0] < 0.5 || l[0] > 0.5005 || l[1] != 2 {
+		t.Errorf("unexpected corrected latencies of the second step: %v", l)
+	}
+}
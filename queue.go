@@ -0,0 +1,60 @@
+package main
+
+// planQueue is a FIFO queue of plans waiting for an execution slot. Plans with
+// a higher priority are placed before the ones with a lower priority, keeping
+// the submission order between plans with the same priority.
+type planQueue struct {
+	items []queuedPlan
+}
+
+type queuedPlan struct {
+	ID   string
+	Plan Plan
+}
+
+func (q *planQueue) Push(id string, plan Plan) {
+	i := len(q.items)
+	for j, item := range q.items {
+		if item.Plan.Priority < plan.Priority {
+			i = j
+			break
+		}
+	}
+	q.items = append(q.items, queuedPlan{})
+	copy(q.items[i+1:], q.items[i:])
+	q.items[i] = queuedPlan{ID: id, Plan: plan}
+}
+
+func (q *planQueue) Pop() (queuedPlan, bool) {
+	if len(q.items) == 0 {
+		return queuedPlan{}, false
+	}
+	item := q.items[0]
+	q.items = q.items[1:]
+	return item, true
+}
+
+func (q *planQueue) Remove(id string) bool {
+	for i, item := range q.items {
+		if item.ID == id {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Position returns the 1-based position of the plan in the queue or 0 if it
+// is not queued
+func (q *planQueue) Position(id string) int {
+	for i, item := range q.items {
+		if item.ID == id {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (q *planQueue) Len() int {
+	return len(q.items)
+}
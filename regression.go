@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kpacha/load-test/db"
+	"github.com/kpacha/load-test/requester"
+)
+
+// internalKeyPrefix marks the keys of the DB not holding test results
+const internalKeyPrefix = "_"
+
+// baselinesKey is the key of the DB storing the baselines
+const baselinesKey = internalKeyPrefix + "baselines"
+
+var baselinesMutex = new(sync.Mutex)
+
+// isInternalKey checks if the key is reserved for the internal documents
+func isInternalKey(key string) bool {
+	return strings.HasPrefix(key, internalKeyPrefix)
+}
+
+// Tolerances define how much worse than the baseline a step can be
+type Tolerances struct {
+	// RPSDrop is the max share of throughput lost (0.1 = 10%)
+	RPSDrop float64
+	// Percentiles are the latency percentiles checked
+	Percentiles []float64
+	// LatencyIncrease is the max share of latency added to every percentile
+	LatencyIncrease float64
+	// ErrorIncrease is the max increase of the error ratio (0.01 = 1 point)
+	ErrorIncrease float64
+}
+
+// DefaultTolerances are used when a baseline is marked without tolerances
+var DefaultTolerances = Tolerances{
+	RPSDrop:         0.1,
+	Percentiles:     []float64{50, 99},
+	LatencyIncrease: 0.2,
+	ErrorIncrease:   0.01,
+}
+
+// Baseline is a snapshot of the steps of a stored test, so the runs with the
+// same name or tag can be checked against it even after the test is
+// overwritten
+type Baseline struct {
+	// Scope is "name:<plan name>" or "tag:<tag>"
+	Scope      string
+	ID         string
+	Created    time.Time
+	Tolerances Tolerances
+	Rate       bool `json:",omitempty"`
+	Steps      []BaselineStep
+}
+
+type BaselineStep struct {
+	Load       int
+	Rps        float64
+	ErrorRatio float64
+	Latencies  *requester.Histogram
+}
+
+// Verdict is the outcome of checking a run against its baseline
+type Verdict struct {
+	Baseline   string
+	Scope      string
+	Passed     bool
+	Violations []string `json:",omitempty"`
+}
+
+func nameScope(name string) string { return "name:" + name }
+func tagScope(tag string) string   { return "tag:" + tag }
+
+func newBaseline(scope, id string, res Result, tolerances Tolerances) Baseline {
+	b := Baseline{Scope: scope, ID: id, Created: time.Now(), Tolerances: tolerances}
+	for i := range res.Reports {
+		r := &res.Reports[i]
+		b.Rate = r.Rate > 0
+		b.Steps = append(b.Steps, BaselineStep{
+			Load:       stepLoad(*r),
+			Rps:        r.Rps,
+			ErrorRatio: r.ErrorRatio(),
+			Latencies:  r.LatencyHistogram(),
+		})
+	}
+	return b
+}
+
+func stepLoad(r requester.Report) int {
+	if r.Rate > 0 {
+		return r.Rate
+	}
+	return r.C
+}
+
+func (b Baseline) stepLabel(load int) string {
+	if b.Rate {
+		return fmt.Sprintf("%d rps", load)
+	}
+	return fmt.Sprintf("C=%d", load)
+}
+
+// evaluate checks the steps of the result against the ones of the baseline
+// with the same load. Steps of the baseline not run are violations too.
+func (b Baseline) evaluate(res Result) Verdict {
+	v := Verdict{Baseline: b.ID, Scope: b.Scope, Violations: []string{}}
+	t := b.Tolerances
+
+	steps := map[stepKey]*requester.Report{}
+	seen := map[int]int{}
+	for i := range res.Reports {
+		load := stepLoad(res.Reports[i])
+		steps[stepKey{load, seen[load]}] = &res.Reports[i]
+		seen[load]++
+	}
+
+	seen = map[int]int{}
+	for _, base := range b.Steps {
+		k := stepKey{base.Load, seen[base.Load]}
+		seen[base.Load]++
+		label := b.stepLabel(base.Load)
+		r, ok := steps[k]
+		if !ok {
+			v.Violations = append(v.Violations, fmt.Sprintf("step %s: not run", label))
+			continue
+		}
+
+		if t.RPSDrop > 0 && base.Rps > 0 {
+			if drop := (base.Rps - r.Rps) / base.Rps; drop > t.RPSDrop {
+				v.Violations = append(v.Violations, fmt.Sprintf("step %s: throughput dropped %.1f%% (from %.3f to %.3f rps), above %g%%", label, 100*drop, base.Rps, r.Rps, 100*t.RPSDrop))
+			}
+		}
+		if t.LatencyIncrease > 0 && base.Latencies.Count() > 0 && r.HasLatencies() {
+			for _, p := range t.Percentiles {
+				before := base.Latencies.Percentile(p)
+				after := time.Duration(r.Percentile(p) * float64(time.Second))
+				if before <= 0 {
+					continue
+				}
+				if inc := float64(after-before) / float64(before); inc > t.LatencyIncrease {
+					v.Violations = append(v.Violations, fmt.Sprintf("step %s: p%g latency increased %.1f%% (from %s to %s), above %g%%", label, p, 100*inc, before, after, 100*t.LatencyIncrease))
+				}
+			}
+		}
+		if t.ErrorIncrease > 0 {
+			if inc := r.ErrorRatio() - base.ErrorRatio; inc > t.ErrorIncrease {
+				v.Violations = append(v.Violations, fmt.Sprintf("step %s: error ratio increased from %.4f to %.4f, above %g", label, base.ErrorRatio, r.ErrorRatio(), t.ErrorIncrease))
+			}
+		}
+	}
+	v.Passed = len(v.Violations) == 0
+	return v
+}
+
+// loadBaselines returns the baselines stored in the DB, by scope
+func loadBaselines(store db.DB) (map[string]Baseline, error) {
+	res := map[string]Baseline{}
+	r, err := store.Get(baselinesKey)
+	switch err {
+	case db.ErrNotFound:
+		return res, nil
+	case nil:
+	default:
+		return nil, err
+	}
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decoding the baselines: %s", err.Error())
+	}
+	return res, nil
+}
+
+func saveBaselines(store db.DB, baselines map[string]Baseline) error {
+	data, err := json.Marshal(baselines)
+	if err != nil {
+		return fmt.Errorf("encoding the baselines: %s", err.Error())
+	}
+	if _, err := store.Set(baselinesKey, bytes.NewBuffer(data)); err != nil {
+		return fmt.Errorf("storing the baselines: %s", err.Error())
+	}
+	return nil
+}
+
+// setBaseline stores the baseline, replacing the one of the same scope
+func setBaseline(store db.DB, b Baseline) error {
+	baselinesMutex.Lock()
+	defer baselinesMutex.Unlock()
+
+	baselines, err := loadBaselines(store)
+	if err != nil {
+		return err
+	}
+	baselines[b.Scope] = b
+	return saveBaselines(store, baselines)
+}
+
+// removeBaseline deletes the baseline of the scope
+func removeBaseline(store db.DB, scope string) error {
+	baselinesMutex.Lock()
+	defer baselinesMutex.Unlock()
+
+	baselines, err := loadBaselines(store)
+	if err != nil {
+		return err
+	}
+	if _, ok := baselines[scope]; !ok {
+		return db.ErrNotFound
+	}
+	delete(baselines, scope)
+	return saveBaselines(store, baselines)
+}
+
+// baselineFor returns the baseline of the plan: the one of its name or, if
+// there is none, the one of its first tag with a baseline
+func baselineFor(store db.DB, plan Plan) (*Baseline, error) {
+	baselinesMutex.Lock()
+	defer baselinesMutex.Unlock()
+
+	baselines, err := loadBaselines(store)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []string{nameScope(plan.Name)}
+	for _, tag := range plan.Tags {
+		scopes = append(scopes, tagScope(tag))
+	}
+	for _, scope := range scopes {
+		if b, ok := baselines[scope]; ok {
+			return &b, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kpacha/load-test/db"
+	"github.com/kpacha/load-test/requester"
+)
+
+func regressionReport(c int, rps float64, lats []float64, errors int) requester.Report {
+	r := requester.Report{C: c}
+	r.Rps = rps
+	r.Lats = lats
+	r.NumRes = int64(len(lats) + errors)
+	r.ErrorDist = map[string]int{}
+	if errors > 0 {
+		r.ErrorDist["timeout"] = errors
+	}
+	return r
+}
+
+func TestBaseline_evaluate(t *testing.T) {
+	baseline := newBaseline(nameScope("svc"), "svc", Result{Reports: []requester.Report{
+		regressionReport(1, 100, []float64{0.01, 0.01, 0.02}, 0),
+		regressionReport(2, 200, []float64{0.01, 0.01, 0.02}, 0),
+		regressionReport(3, 300, []float64{0.01, 0.01, 0.02}, 0),
+	}}, DefaultTolerances)
+
+	v := baseline.evaluate(Result{Reports: []requester.Report{
+		regressionReport(1, 95, []float64{0.01, 0.011, 0.021}, 0),
+		regressionReport(2, 150, []float64{0.02, 0.02, 0.03}, 1),
+	}})
+	if v.Passed || v.Baseline != "svc" || v.Scope != "name:svc" {
+		t.Errorf("unexpected verdict: %+v", v)
+	}
+	expected := []string{
+		"step C=2: throughput dropped 25.0% (from 200.000 to 150.000 rps), above 10%",
+		"step C=2: p50 latency increased 100.",
+		"step C=2: p99 latency increased 50.",
+		"step C=2: error ratio increased from 0.0000 to 0.2500, above 0.01",
+		"step C=3: not run",
+	}
+	if len(v.Violations) != len(expected) {
+		t.Errorf("unexpected violations: %v", v.Violations)
+		return
+	}
+	for i, prefix := range expected {
+		if !strings.HasPrefix(v.Violations[i], prefix) {
+			t.Errorf("unexpected violation #%d: %s", i, v.Violations[i])
+		}
+	}
+
+	v = baseline.evaluate(Result{Reports: []requester.Report{
+		regressionReport(1, 100, []float64{0.01, 0.01, 0.02}, 0),
+		regressionReport(2, 210, []float64{0.01, 0.01, 0.02}, 0),
+		regressionReport(3, 300, []float64{0.005, 0.01, 0.02}, 0),
+	}})
+	if !v.Passed || len(v.Violations) != 0 {
+		t.Errorf("unexpected verdict: %+v", v)
+	}
+}
+
+func Test_baselineFor(t *testing.T) {
+	store := db.NewInMemory()
+	res := Result{Reports: []requester.Report{regressionReport(1, 100, []float64{0.01}, 0)}}
+	for _, b := range []Baseline{
+		newBaseline(nameScope("svc"), "a", res, DefaultTolerances),
+		newBaseline(tagScope("nightly"), "b", res, DefaultTolerances),
+	} {
+		if err := setBaseline(store, b); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	for _, tc := range []struct {
+		plan     Plan
+		expected string
+	}{
+		{Plan{Name: "svc", Tags: []string{"nightly"}}, "a"},
+		{Plan{Name: "other", Tags: []string{"api", "nightly"}}, "b"},
+		{Plan{Name: "other"}, ""},
+	} {
+		b, err := baselineFor(store, tc.plan)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if (b == nil && tc.expected != "") || (b != nil && b.ID != tc.expected) {
+			t.Errorf("unexpected baseline for %+v: %+v", tc.plan, b)
+		}
+	}
+
+	if err := removeBaseline(store, nameScope("svc")); err != nil {
+		t.Error(err)
+	}
+	if err := removeBaseline(store, nameScope("svc")); err != db.ErrNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if b, _ := baselineFor(store, Plan{Name: "svc", Tags: []string{"nightly"}}); b == nil || b.ID != "b" {
+		t.Errorf("unexpected baseline: %+v", b)
+	}
+}
+
+func Test_executor_Run_regression(t *testing.T) {
+	store := db.NewInMemory()
+	baseline := newBaseline(nameScope("svc"), "svc", Result{Reports: []requester.Report{
+		regressionReport(1, 100, []float64{0.01}, 0),
+		regressionReport(2, 100, []float64{0.01}, 0),
+	}}, DefaultTolerances)
+	if err := setBaseline(store, baseline); err != nil {
+		t.Error(err)
+		return
+	}
+
+	exec := executor{
+		DB:     store,
+		Events: NewBroker(),
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				return bytes.NewBufferString(`{"Rps":50,"Lats":[0.01],"NumRes":1}`)
+			})
+		},
+	}
+	p := Plan{Name: "svc", Min: 1, Max: 3, Steps: 1, Duration: 1, Request: requester.Request{Method: "GET", URL: "/"}}
+	if _, err := exec.Run(context.Background(), p); err != nil {
+		t.Error(err)
+		return
+	}
+
+	r, _ := store.Get("svc")
+	res, err := decodeResult(r)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if res.Regression == nil || res.Regression.Passed || len(res.Regression.Violations) != 2 {
+		t.Errorf("unexpected verdict: %+v", res.Regression)
+	}
+}
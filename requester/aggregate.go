@@ -0,0 +1,206 @@
+package requester
+
+import (
+	"sort"
+	"time"
+
+	hey "github.com/rakyll/hey/requester"
+)
+
+var percentiles = []int{10, 25, 50, 75, 90, 95, 99}
+
+const histogramBuckets = 10
+
+// aggregate summarizes the samples into a report with the same shape of the
+// ones generated by hey, so both engines can be charted the same way. The
+// latencies and the offsets are not copied into the report because the raw
+// samples and the histogram of the latencies are kept apart.
+func aggregate(samples []sample, total time.Duration) hey.Report {
+	r := hey.Report{
+		Total:          total,
+		ErrorDist:      map[string]int{},
+		StatusCodeDist: map[int]int{},
+		NumRes:         int64(len(samples)),
+	}
+	if total > 0 {
+		r.Rps = float64(len(samples)) / total.Seconds()
+	}
+
+	var lats, conn, dns, req, res, delay []float64
+	for _, s := range samples {
+		if s.err != nil {
+			r.ErrorDist[s.err.Error()]++
+			continue
+		}
+		r.AvgTotal += s.duration.Seconds()
+		r.AvgConn += s.connDuration.Seconds()
+		r.AvgDNS += s.dnsDuration.Seconds()
+		r.AvgReq += s.reqDuration.Seconds()
+		r.AvgRes += s.resDuration.Seconds()
+		r.AvgDelay += s.delayDuration.Seconds()
+		lats = append(lats, s.duration.Seconds())
+		conn = append(conn, s.connDuration.Seconds())
+		dns = append(dns, s.dnsDuration.Seconds())
+		req = append(req, s.reqDuration.Seconds())
+		res = append(res, s.resDuration.Seconds())
+		delay = append(delay, s.delayDuration.Seconds())
+		r.StatusCodeDist[s.statusCode]++
+		if s.contentLength > 0 {
+			r.SizeTotal += s.contentLength
+		}
+	}
+
+	n := len(lats)
+	if n == 0 {
+		return r
+	}
+
+	r.Average = r.AvgTotal / float64(n)
+	r.AvgConn /= float64(n)
+	r.AvgDNS /= float64(n)
+	r.AvgReq /= float64(n)
+	r.AvgRes /= float64(n)
+	r.AvgDelay /= float64(n)
+	r.SizeReq = r.SizeTotal / int64(n)
+
+	sort.Float64s(lats)
+	r.Fastest = lats[0]
+	r.Slowest = lats[n-1]
+	r.ConnMin, r.ConnMax = bounds(conn)
+	r.DnsMin, r.DnsMax = bounds(dns)
+	r.ReqMin, r.ReqMax = bounds(req)
+	r.ResMin, r.ResMax = bounds(res)
+	r.DelayMin, r.DelayMax = bounds(delay)
+
+	r.LatencyDistribution = latencyDistribution(lats)
+	r.Histogram = histogram(lats)
+
+	return r
+}
+
+// latencyHistogram records the latencies of the successful requests
+func latencyHistogram(samples []sample) *Histogram {
+	h := NewHistogram()
+	for _, s := range samples {
+		if s.err == nil {
+			h.Record(s.duration)
+		}
+	}
+	return h
+}
+
+// rawSamples exports the samples
+func rawSamples(samples []sample) []Sample {
+	res := make([]Sample, len(samples))
+	for i, s := range samples {
+		res[i] = Sample{
+			Name:    s.name,
+			Offset:  s.offset.Seconds(),
+			Latency: s.duration.Seconds(),
+			Conn:    s.connDuration.Seconds(),
+			DNS:     s.dnsDuration.Seconds(),
+			Req:     s.reqDuration.Seconds(),
+			Delay:   s.delayDuration.Seconds(),
+			Res:     s.resDuration.Seconds(),
+			Status:  s.statusCode,
+			Bytes:   s.contentLength,
+		}
+		if s.err != nil {
+			res[i].Error = s.err.Error()
+		}
+	}
+	return res
+}
+
+// breakdown summarizes the samples of every request of a mix, keeping the
+// order of the given names
+func breakdown(samples []sample, total time.Duration, names []string) []RequestSummary {
+	groups := map[string][]sample{}
+	for _, s := range samples {
+		groups[s.name] = append(groups[s.name], s)
+	}
+	res := make([]RequestSummary, len(names))
+	for i, name := range names {
+		r := aggregate(groups[name], total)
+		res[i] = RequestSummary{
+			Name:           name,
+			NumRes:         r.NumRes,
+			Rps:            r.Rps,
+			Average:        r.Average,
+			Fastest:        r.Fastest,
+			Slowest:        r.Slowest,
+			StatusCodeDist: r.StatusCodeDist,
+		}
+		for _, n := range r.ErrorDist {
+			res[i].Errors += n
+		}
+		for _, ld := range r.LatencyDistribution {
+			switch ld.Percentage {
+			case 50:
+				res[i].P50 = ld.Latency
+			case 99:
+				res[i].P99 = ld.Latency
+			}
+		}
+	}
+	return res
+}
+
+func latencyDistribution(sorted []float64) []hey.LatencyDistribution {
+	res := make([]hey.LatencyDistribution, len(percentiles))
+	for i, p := range percentiles {
+		res[i] = hey.LatencyDistribution{Percentage: p, Latency: percentile(sorted, p)}
+	}
+	return res
+}
+
+// percentile returns the nearest-rank percentile of the sorted values
+func percentile(sorted []float64, p int) float64 {
+	idx := (len(sorted)*p + 99) / 100
+	if idx > 0 {
+		idx--
+	}
+	return sorted[idx]
+}
+
+func histogram(sorted []float64) []hey.Bucket {
+	fastest, slowest := sorted[0], sorted[len(sorted)-1]
+	size := (slowest - fastest) / histogramBuckets
+	res := make([]hey.Bucket, histogramBuckets+1)
+	for i := range res {
+		res[i].Mark = fastest + size*float64(i)
+	}
+	res[histogramBuckets].Mark = slowest
+
+	bi := 0
+	for _, l := range sorted {
+		for bi < histogramBuckets && l > res[bi].Mark {
+			bi++
+		}
+		res[bi].Count++
+	}
+	for i := range res {
+		res[i].Frequency = float64(res[i].Count) / float64(len(sorted))
+	}
+	return res
+}
+
+func sortedCopy(v []float64) []float64 {
+	res := make([]float64, len(v))
+	copy(res, v)
+	sort.Float64s(res)
+	return res
+}
+
+func bounds(v []float64) (float64, float64) {
+	lo, hi := v[0], v[0]
+	for _, x := range v[1:] {
+		if x < lo {
+			lo = x
+		}
+		if x > hi {
+			hi = x
+		}
+	}
+	return lo, hi
+}
@@ -0,0 +1,105 @@
+package requester
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewNativeJSON returns a Requester implementing a closed model with the native
+// engine: the c argument of its Run method is the number of workers sending the
+// request one after the other. Unlike the hey based one, its reports include
+// the raw samples of every request.
+func NewNativeJSON(req *http.Request, timeout time.Duration) Requester {
+	body := new(bytes.Buffer)
+	if req != nil && req.Body != nil {
+		body.ReadFrom(req.Body)
+		req.Body.Close()
+	}
+	return closedRequester{
+		Generator: singleMix(req, body.Bytes()),
+		Timeout:   timeout,
+	}
+}
+
+type closedRequester struct {
+	Generator generator
+	Timeout   time.Duration
+}
+
+func (r closedRequester) Run(ctx context.Context, c int) io.Reader {
+	log.Println("starting the load test")
+	report := r.run(ctx, c, 0)
+	log.Println("load test ended")
+
+	buf := new(bytes.Buffer)
+	json.NewEncoder(buf).Encode(report)
+	return buf
+}
+
+// RunN stops after n iterations or once the timeout is reached
+func (r closedRequester) RunN(ctx context.Context, c, n int) io.Reader {
+	buf := new(bytes.Buffer)
+	json.NewEncoder(buf).Encode(r.run(ctx, min(c, n), n))
+	return buf
+}
+
+// run starts c virtual users iterating until the timeout is reached or, if
+// limit is positive, after limit iterations. The requests interrupted by
+// the timeout are discarded.
+func (r closedRequester) run(ctx context.Context, c, limit int) Report {
+	report := Report{C: c}
+	if c <= 0 || r.Generator == nil {
+		return report
+	}
+	r.Generator.reset()
+
+	localCtx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	client := r.Generator.newClient(c, r.Timeout)
+	mu := new(sync.Mutex)
+	samples := []sample{}
+	var sent int64
+
+	wg := new(sync.WaitGroup)
+	start := time.Now()
+	for i := 0; i < c; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u := r.Generator.newUser(client)
+			local := []sample{}
+			for localCtx.Err() == nil {
+				if limit > 0 && atomic.AddInt64(&sent, 1) > int64(limit) {
+					break
+				}
+				ss, ok := r.Generator.iterate(localCtx, u, start)
+				local = append(local, ss...)
+				if !ok {
+					break
+				}
+			}
+			mu.Lock()
+			samples = append(samples, local...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	total := time.Since(start)
+
+	report.Report = aggregate(samples, total)
+	report.Start = start
+	report.Samples = rawSamples(samples)
+	report.Latencies = latencyHistogram(samples)
+	if names := r.Generator.names(); len(names) > 1 {
+		report.Breakdown = breakdown(samples, total, names)
+	}
+	return report
+}
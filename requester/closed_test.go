@@ -0,0 +1,46 @@
+package requester
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewNativeJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	r := NewNativeJSON(req, 200*time.Millisecond)
+
+	report := Report{}
+	if err := json.NewDecoder(r.Run(context.Background(), 2)).Decode(&report); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if report.C != 2 || report.NumRes == 0 {
+		t.Errorf("unexpected report: c %d, responses %d", report.C, report.NumRes)
+	}
+	if report.Start.IsZero() {
+		t.Error("the start of the step should be set")
+	}
+	if len(report.Samples) != int(report.NumRes) {
+		t.Errorf("unexpected number of samples: %d", len(report.Samples))
+		return
+	}
+	for _, s := range report.Samples {
+		if s.Status != http.StatusOK || s.Bytes != 5 || s.Latency <= 0 || s.Error != "" {
+			t.Errorf("unexpected sample: %+v", s)
+			return
+		}
+	}
+	if report.Breakdown != nil {
+		t.Errorf("unexpected breakdown: %+v", report.Breakdown)
+	}
+}
@@ -0,0 +1,178 @@
+package requester
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+const maxIdleConn = 500
+
+// sample holds the data collected for a single request
+type sample struct {
+	// name of the request of the mix
+	name          string
+	offset        time.Duration
+	duration      time.Duration
+	connDuration  time.Duration
+	dnsDuration   time.Duration
+	reqDuration   time.Duration
+	resDuration   time.Duration
+	delayDuration time.Duration
+	statusCode    int
+	contentLength int64
+	err           error
+}
+
+func newClient(req *http.Request, conns int, timeout time.Duration) *http.Client {
+	serverName := ""
+	if req != nil {
+		serverName = req.Host
+	}
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			ServerName:         serverName,
+		},
+		MaxIdleConnsPerHost: min(conns, maxIdleConn),
+		TLSNextProto:        make(map[string]func(string, *tls.Conn) http.RoundTripper),
+	}
+	return &http.Client{Transport: tr, Timeout: timeout}
+}
+
+// maxCapturedBody is the max size of the response bodies kept to extract values
+const maxCapturedBody = 1 << 20
+
+// response holds the parts of a response required to extract values from it
+type response struct {
+	header http.Header
+	body   []byte
+}
+
+// doRequest sends the request and traces the duration of each of its phases.
+// The offset of the sample is relative to the given start time.
+func doRequest(ctx context.Context, c *http.Client, req *http.Request, start time.Time) sample {
+	return doCapture(ctx, c, req, start, nil)
+}
+
+// phases records the times of the phases of a request. The trace callbacks
+// may run on the goroutines of the transport, so it is guarded by a mutex.
+type phases struct {
+	mu                                                  sync.Mutex
+	dnsStart, connStart, reqStart, delayStart, resStart time.Time
+	dns, conn, req, delay                               time.Duration
+}
+
+func (p *phases) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.dnsStart = time.Now()
+		},
+		DNSDone: func(dnsInfo httptrace.DNSDoneInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.dns = time.Since(p.dnsStart)
+		},
+		GetConn: func(h string) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.connStart = time.Now()
+		},
+		GotConn: func(connInfo httptrace.GotConnInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if !connInfo.Reused {
+				p.conn = time.Since(p.connStart)
+			}
+			p.reqStart = time.Now()
+		},
+		WroteRequest: func(w httptrace.WroteRequestInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.req = time.Since(p.reqStart)
+			p.delayStart = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.delay = time.Since(p.delayStart)
+			p.resStart = time.Now()
+		},
+	}
+}
+
+// doCapture works like doRequest but it also fills the given response, if any,
+// with the headers and the body received
+func doCapture(ctx context.Context, c *http.Client, req *http.Request, start time.Time, res *response) sample {
+	p := &phases{}
+	s := sample{}
+
+	begin := time.Now()
+	s.offset = begin.Sub(start)
+	resp, err := c.Do(req.WithContext(httptrace.WithClientTrace(ctx, p.trace())))
+	if err == nil {
+		s.statusCode = resp.StatusCode
+		if res != nil {
+			res.header = resp.Header
+			res.body, _ = io.ReadAll(io.LimitReader(resp.Body, maxCapturedBody))
+			s.contentLength = int64(len(res.body))
+		}
+		n, _ := io.Copy(io.Discard, resp.Body)
+		s.contentLength += n
+		resp.Body.Close()
+	}
+	s.err = err
+	end := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.dnsDuration, s.connDuration, s.reqDuration, s.delayDuration = p.dns, p.conn, p.req, p.delay
+	if !p.resStart.IsZero() {
+		s.resDuration = end.Sub(p.resStart)
+	}
+	s.duration = end.Sub(begin)
+	return s
+}
+
+// cloneRequest returns a shallow copy of the request with its own headers and
+// a fresh body
+func cloneRequest(r *http.Request, body []byte) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Header = r.Header.Clone()
+	if len(body) > 0 {
+		r2.Body = io.NopCloser(bytes.NewReader(body))
+		r2.ContentLength = int64(len(body))
+	}
+	return r2
+}
+
+// generator produces the requests sent by the virtual users of the native
+// engines
+type generator interface {
+	// iterate sends the requests of a single iteration of the user. The
+	// requests interrupted by the cancellation of the context are discarded.
+	// It returns false if no more iterations can be run.
+	iterate(ctx context.Context, u *user, start time.Time) ([]sample, bool)
+	// newUser returns a virtual user sending its requests with the client
+	newUser(c *http.Client) *user
+	// newClient returns the client shared by all the virtual users
+	newClient(conns int, timeout time.Duration) *http.Client
+	// names of the requests, in the order they must be reported
+	names() []string
+	// reset prepares the generator for a new run, so a sequential feeder
+	// hands its rows again
+	reset()
+}
+
+// user is the state of a virtual user
+type user struct {
+	client *http.Client
+}
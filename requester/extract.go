@@ -0,0 +1,126 @@
+package requester
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ExtractJSON reads a value of a JSON body with a dot separated path
+	// (e.g. data.items.0.id)
+	ExtractJSON = "json"
+	// ExtractRegex reads the first group (or the whole match) of a regular
+	// expression applied to the body
+	ExtractRegex = "regex"
+	// ExtractHeader reads a header of the response
+	ExtractHeader = "header"
+	// ExtractCookie reads a cookie set by the response
+	ExtractCookie = "cookie"
+)
+
+// Extractor captures a value from a response and stores it in the Var
+// variable, so the next steps of the scenario can use it
+type Extractor struct {
+	Var  string
+	From string
+	Expr string
+}
+
+type extractor struct {
+	name  string
+	from  string
+	expr  string
+	regex *regexp.Regexp
+}
+
+func (e Extractor) compile() (extractor, error) {
+	ex := extractor{name: e.Var, from: e.From, expr: e.Expr}
+	if e.Var == "" {
+		return ex, fmt.Errorf("extractor without variable")
+	}
+	switch e.From {
+	case ExtractJSON, ExtractHeader, ExtractCookie:
+	case ExtractRegex:
+		r, err := regexp.Compile(e.Expr)
+		if err != nil {
+			return ex, fmt.Errorf("compiling the regex of %s: %s", e.Var, err.Error())
+		}
+		ex.regex = r
+	default:
+		return ex, fmt.Errorf("unknown extractor source %q for %s", e.From, e.Var)
+	}
+	return ex, nil
+}
+
+func (e extractor) extract(res *response) (string, error) {
+	switch e.from {
+	case ExtractHeader:
+		if v := res.header.Get(e.expr); v != "" {
+			return v, nil
+		}
+	case ExtractCookie:
+		for _, c := range (&http.Response{Header: res.header}).Cookies() {
+			if c.Name == e.expr {
+				return c.Value, nil
+			}
+		}
+	case ExtractRegex:
+		if m := e.regex.FindSubmatch(res.body); m != nil {
+			if len(m) > 1 {
+				return string(m[1]), nil
+			}
+			return string(m[0]), nil
+		}
+	case ExtractJSON:
+		var v interface{}
+		if err := json.Unmarshal(res.body, &v); err != nil {
+			return "", fmt.Errorf("extracting %s: %s", e.name, err.Error())
+		}
+		if v, ok := jsonPath(v, e.expr); ok {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("extracting %s: %s %q not found", e.name, e.from, e.expr)
+}
+
+// jsonPath walks the decoded JSON following the dot separated path
+func jsonPath(v interface{}, path string) (string, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			switch node := v.(type) {
+			case map[string]interface{}:
+				child, ok := node[key]
+				if !ok {
+					return "", false
+				}
+				v = child
+			case []interface{}:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(node) {
+					return "", false
+				}
+				v = node[i]
+			default:
+				return "", false
+			}
+		}
+	}
+
+	switch value := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	}
+	b, err := json.Marshal(v)
+	return string(b), err == nil
+}
@@ -0,0 +1,167 @@
+package requester
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	FeederCSV   = "csv"
+	FeederJSONL = "jsonl"
+
+	// FeederSequential uses every row once per step, in order
+	FeederSequential = "sequential"
+	// FeederCircular uses the rows in order, starting again after the last one
+	FeederCircular = "circular"
+	// FeederRandom picks a random row every time
+	FeederRandom = "random"
+)
+
+// Feeder is a data file whose rows feed the variables of the request templates.
+// Every iteration of a virtual user consumes a row.
+type Feeder struct {
+	// Format is csv (with a header line naming the fields) or jsonl (an object
+	// per line)
+	Format string
+	Order  string `json:",omitempty"`
+	Data   string
+}
+
+// Validate checks the data can be parsed and the order is supported
+func (f Feeder) Validate() error {
+	_, err := f.compile()
+	return err
+}
+
+// Len returns the number of rows of the feeder or 0 if it is not valid
+func (f Feeder) Len() int {
+	rows, err := f.parse()
+	if err != nil {
+		return 0
+	}
+	return len(rows)
+}
+
+func (f Feeder) parse() ([]map[string]string, error) {
+	switch f.Format {
+	case FeederCSV:
+		return parseCSV(f.Data)
+	case FeederJSONL:
+		return parseJSONL(f.Data)
+	}
+	return nil, fmt.Errorf("unknown feeder format %q", f.Format)
+}
+
+func parseCSV(data string) ([]map[string]string, error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the CSV header: %s", err.Error())
+	}
+	rows := []map[string]string{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading the CSV: %s", err.Error())
+		}
+		row := make(map[string]string, len(header))
+		for i, field := range header {
+			row[field] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseJSONL(data string) ([]map[string]string, error) {
+	rows := []map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxCapturedBody)
+	for line := 1; scanner.Scan(); line++ {
+		txt := strings.TrimSpace(scanner.Text())
+		if txt == "" {
+			continue
+		}
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(txt), &fields); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		row := make(map[string]string, len(fields))
+		for k, v := range fields {
+			row[k], _ = jsonPath(v, "")
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading the JSONL: %s", err.Error())
+	}
+	return rows, nil
+}
+
+// feeder hands the rows to the virtual users. It is safe for concurrent use.
+type feeder struct {
+	rows  []map[string]string
+	order string
+	next  int64
+}
+
+func (f Feeder) compile() (*feeder, error) {
+	rows, err := f.parse()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("the feeder has no rows")
+	}
+	switch f.Order {
+	case "":
+		f.Order = FeederSequential
+	case FeederSequential, FeederCircular, FeederRandom:
+	default:
+		return nil, fmt.Errorf("unknown feeder order %q", f.Order)
+	}
+	return &feeder{rows: rows, order: f.Order}, nil
+}
+
+// reset starts handing the rows from the first one again
+func (f *feeder) reset() {
+	if f != nil {
+		atomic.StoreInt64(&f.next, 0)
+	}
+}
+
+// vars returns a copy of the next row or false once a sequential feeder is
+// exhausted. A nil feeder always returns an empty set of variables.
+func (f *feeder) vars() (map[string]string, bool) {
+	if f == nil {
+		return map[string]string{}, true
+	}
+	var row map[string]string
+	switch f.order {
+	case FeederRandom:
+		row = f.rows[rand.IntN(len(f.rows))]
+	case FeederCircular:
+		row = f.rows[(atomic.AddInt64(&f.next, 1)-1)%int64(len(f.rows))]
+	default:
+		i := atomic.AddInt64(&f.next, 1) - 1
+		if i >= int64(len(f.rows)) {
+			return nil, false
+		}
+		row = f.rows[i]
+	}
+	res := make(map[string]string, len(row))
+	for k, v := range row {
+		res[k] = v
+	}
+	return res, true
+}
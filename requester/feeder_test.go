@@ -0,0 +1,129 @@
+package requester
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFeeder_parse(t *testing.T) {
+	expected := []map[string]string{
+		{"id": "1", "user": "ann"},
+		{"id": "2", "user": "bob, jr"},
+	}
+	for _, f := range []Feeder{
+		{Format: FeederCSV, Data: "id,user\n1,ann\n2,\"bob, jr\"\n"},
+		{Format: FeederJSONL, Data: "{\"id\":1,\"user\":\"ann\"}\n\n{\"id\":2,\"user\":\"bob, jr\"}\n"},
+	} {
+		rows, err := f.parse()
+		if err != nil {
+			t.Errorf("%s: %s", f.Format, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Errorf("%s: unexpected rows: %v", f.Format, rows)
+		}
+		if f.Len() != 2 {
+			t.Errorf("%s: unexpected length: %d", f.Format, f.Len())
+		}
+	}
+
+	for _, f := range []Feeder{
+		{Format: "xml", Data: "<a/>"},
+		{Format: FeederCSV, Data: ""},
+		{Format: FeederCSV, Data: "id\n"},
+		{Format: FeederCSV, Data: "id,user\n1\n"},
+		{Format: FeederJSONL, Data: "{\n"},
+		{Format: FeederCSV, Data: "id\n1\n", Order: "reverse"},
+	} {
+		if err := f.Validate(); err == nil {
+			t.Errorf("error expected for %+v", f)
+		}
+	}
+}
+
+func Test_feeder_vars(t *testing.T) {
+	data := "id\n1\n2\n3\n"
+	for order, expected := range map[string][]string{
+		FeederSequential: {"1", "2", "3", "", ""},
+		FeederCircular:   {"1", "2", "3", "1", "2"},
+	} {
+		f, err := Feeder{Format: FeederCSV, Order: order, Data: data}.compile()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		ids := []string{}
+		for range expected {
+			vars, _ := f.vars()
+			ids = append(ids, vars["id"])
+		}
+		if !reflect.DeepEqual(ids, expected) {
+			t.Errorf("%s: unexpected rows: %v", order, ids)
+		}
+	}
+
+	f, _ := Feeder{Format: FeederCSV, Order: FeederRandom, Data: data}.compile()
+	for i := 0; i < 10; i++ {
+		vars, ok := f.vars()
+		if !ok || vars["id"] == "" {
+			t.Errorf("unexpected random row: %v", vars)
+		}
+		vars["id"] = "changed"
+	}
+	for _, row := range f.rows {
+		if row["id"] == "changed" {
+			t.Error("the rows of the feeder should not be modified")
+		}
+	}
+}
+
+func TestNewWorkloadJSON_feeder(t *testing.T) {
+	mu := new(sync.Mutex)
+	users := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		users[r.URL.Query().Get("user")+":"+r.Header.Get("X-Id")]++
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	r, err := NewWorkloadJSON(Workload{
+		Mix: []Request{{
+			URL:    ts.URL + "/?user={{ .user }}",
+			Header: http.Header{"X-Id": []string{"{{ .id }}"}},
+		}},
+		Feeder: &Feeder{Format: FeederJSONL, Data: "{\"id\":1,\"user\":\"ann\"}\n{\"id\":2,\"user\":\"bob\"}\n{\"id\":3,\"user\":\"cy\"}"},
+	}, time.Second)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	report := Report{}
+	if err := json.NewDecoder(r.Run(context.Background(), 2)).Decode(&report); err != nil {
+		t.Error(err)
+		return
+	}
+	if report.NumRes != 3 {
+		t.Errorf("unexpected number of responses: %d", report.NumRes)
+	}
+	if !reflect.DeepEqual(users, map[string]int{"ann:1": 1, "bob:2": 1, "cy:3": 1}) {
+		t.Errorf("unexpected requests: %v", users)
+	}
+
+	// every run (a step of the plan) uses the rows again
+	report = Report{}
+	if err := json.NewDecoder(r.Run(context.Background(), 2)).Decode(&report); err != nil {
+		t.Error(err)
+		return
+	}
+	if report.NumRes != 3 {
+		t.Errorf("unexpected number of responses: %d", report.NumRes)
+	}
+}
@@ -0,0 +1,265 @@
+package requester
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// the sub-buckets of every power of two keep 3 significant digits, so the
+// values recorded are off by less than 0.1%
+const (
+	subBucketHalfCountMagnitude = 10
+	subBucketCount              = 1 << (subBucketHalfCountMagnitude + 1)
+	subBucketHalfCount          = subBucketCount / 2
+	subBucketMask               = subBucketCount - 1
+)
+
+// Histogram is a high dynamic range histogram of latencies: every value from
+// a nanosecond to hours is recorded with 3 significant digits. Only the
+// buckets with values are kept, so it is small enough to be stored with every
+// report, and histograms of different steps or plans can be merged. In JSON,
+// it is encoded as a base64 string.
+type Histogram struct {
+	counts map[int]int64
+	total  int64
+	min    int64
+	max    int64
+}
+
+// NewHistogram returns an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{counts: map[int]int64{}}
+}
+
+// Record adds a latency to the histogram. Negative values are ignored.
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		return
+	}
+	if h.total == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.counts[bucketIndex(v)]++
+	h.total++
+}
+
+// RecordCorrected adds a latency to the histogram, correcting it for
+// coordinated omission: when it is longer than the expected interval between
+// requests, the requests the client did not send while waiting for it are
+// added too, with the latencies they would have had (d - interval,
+// d - 2*interval, ...)
+func (h *Histogram) RecordCorrected(d, interval time.Duration) {
+	h.Record(d)
+	if interval <= 0 {
+		return
+	}
+	for missing := d - interval; missing >= interval; missing -= interval {
+		h.Record(missing)
+	}
+}
+
+// Merge adds the values recorded by another histogram
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.total == 0 {
+		return
+	}
+	if h.total == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	h.total += other.total
+}
+
+// Count returns the number of values recorded. A nil histogram is empty.
+func (h *Histogram) Count() int64 {
+	if h == nil {
+		return 0
+	}
+	return h.total
+}
+
+// Min returns the lowest value recorded
+func (h *Histogram) Min() time.Duration {
+	if h.Count() == 0 {
+		return 0
+	}
+	return time.Duration(h.min)
+}
+
+// Max returns the highest value recorded
+func (h *Histogram) Max() time.Duration {
+	if h.Count() == 0 {
+		return 0
+	}
+	return time.Duration(h.max)
+}
+
+// Percentile returns the value below which the given percentage of the
+// values fall, so any percentile (e.g. 99.99) can be requested
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.Count() == 0 {
+		return 0
+	}
+	if p >= 100 {
+		return time.Duration(h.max)
+	}
+	target := int64(math.Ceil(float64(h.total) * p / 100))
+	if target < 1 {
+		target = 1
+	}
+	var seen int64
+	for _, i := range h.indexes() {
+		seen += h.counts[i]
+		if seen >= target {
+			return time.Duration(h.clamp(highestEquivalentValue(i)))
+		}
+	}
+	return time.Duration(h.max)
+}
+
+// CountBelow returns the number of values lower than or equal to the given
+// one. The values of the bucket containing it are included.
+func (h *Histogram) CountBelow(d time.Duration) int64 {
+	if h.Count() == 0 {
+		return 0
+	}
+	var res int64
+	for _, i := range h.indexes() {
+		if h.clamp(lowestEquivalentValue(i)) > int64(d) {
+			break
+		}
+		res += h.counts[i]
+	}
+	return res
+}
+
+func (h *Histogram) indexes() []int {
+	res := make([]int, 0, len(h.counts))
+	for i, n := range h.counts {
+		if n > 0 {
+			res = append(res, i)
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+// clamp keeps a value in the range of the recorded ones
+func (h *Histogram) clamp(v int64) int64 {
+	if v < h.min {
+		return h.min
+	}
+	if v > h.max {
+		return h.max
+	}
+	return v
+}
+
+func bucketIndex(v int64) int {
+	pow2Ceiling := 64 - bits.LeadingZeros64(uint64(v)|subBucketMask)
+	bucket := pow2Ceiling - (subBucketHalfCountMagnitude + 1)
+	subBucket := int(v >> uint(bucket))
+	return (bucket+1)<<subBucketHalfCountMagnitude + subBucket - subBucketHalfCount
+}
+
+// lowestEquivalentValue returns the lowest value recorded in the bucket of
+// the given index
+func lowestEquivalentValue(index int) int64 {
+	bucket, subBucket := bucketOf(index)
+	return int64(subBucket) << uint(bucket)
+}
+
+// highestEquivalentValue returns the highest value recorded in the bucket of
+// the given index
+func highestEquivalentValue(index int) int64 {
+	bucket, _ := bucketOf(index)
+	return lowestEquivalentValue(index) + int64(1)<<uint(bucket) - 1
+}
+
+func bucketOf(index int) (int, int) {
+	bucket := index>>subBucketHalfCountMagnitude - 1
+	subBucket := index&(subBucketHalfCount-1) + subBucketHalfCount
+	if bucket < 0 {
+		subBucket -= subBucketHalfCount
+		bucket = 0
+	}
+	return bucket, subBucket
+}
+
+// MarshalBinary encodes the histogram as a list of varints: the min, the max
+// and then the index delta and the count of every bucket with values
+func (h *Histogram) MarshalBinary() ([]byte, error) {
+	indexes := h.indexes()
+	buf := make([]byte, 0, 2*binary.MaxVarintLen64*(len(indexes)+1))
+	buf = binary.AppendUvarint(buf, uint64(h.min))
+	buf = binary.AppendUvarint(buf, uint64(h.max))
+	prev := 0
+	for _, i := range indexes {
+		buf = binary.AppendUvarint(buf, uint64(i-prev))
+		buf = binary.AppendUvarint(buf, uint64(h.counts[i]))
+		prev = i
+	}
+	return buf, nil
+}
+
+func (h *Histogram) UnmarshalBinary(data []byte) error {
+	*h = Histogram{counts: map[int]int64{}}
+	if len(data) == 0 {
+		return nil
+	}
+	values := []uint64{}
+	for len(data) > 0 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("decoding the histogram: invalid varint")
+		}
+		values = append(values, v)
+		data = data[n:]
+	}
+	if len(values) < 2 || len(values)%2 != 0 {
+		return fmt.Errorf("decoding the histogram: unexpected number of values")
+	}
+	h.min, h.max = int64(values[0]), int64(values[1])
+	index := 0
+	for i := 2; i < len(values); i += 2 {
+		index += int(values[i])
+		h.counts[index] += int64(values[i+1])
+		h.total += int64(values[i+1])
+	}
+	return nil
+}
+
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	b, err := h.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(b))
+}
+
+func (h *Histogram) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("decoding the histogram: %s", err.Error())
+	}
+	return h.UnmarshalBinary(data)
+}
@@ -0,0 +1,134 @@
+package requester
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 100000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+	if h.Count() != 100000 || h.Min() != time.Microsecond || h.Max() != 100*time.Millisecond {
+		t.Errorf("unexpected histogram: count %d, min %s, max %s", h.Count(), h.Min(), h.Max())
+	}
+	for p, expected := range map[float64]time.Duration{
+		0:      time.Microsecond,
+		50:     50 * time.Millisecond,
+		99:     99 * time.Millisecond,
+		99.9:   99900 * time.Microsecond,
+		99.99:  99990 * time.Microsecond,
+		99.999: 99999 * time.Microsecond,
+		100:    100 * time.Millisecond,
+	} {
+		if err := math.Abs(float64(h.Percentile(p)-expected)) / float64(expected); err > 0.001 {
+			t.Errorf("unexpected p%g: %s", p, h.Percentile(p))
+		}
+	}
+	if n := h.CountBelow(10 * time.Millisecond); n < 9990 || n > 10010 {
+		t.Errorf("unexpected count below 10ms: %d", n)
+	}
+	if n := h.CountBelow(h.Max()); n != h.Count() {
+		t.Errorf("unexpected count below the max: %d", n)
+	}
+
+	var empty *Histogram
+	if empty.Count() != 0 || empty.Percentile(99) != 0 {
+		t.Error("a nil histogram should be empty")
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	for i := 0; i < 990; i++ {
+		a.Record(time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		b.Record(time.Second)
+	}
+	a.Merge(b)
+	a.Merge(nil)
+	if a.Count() != 1000 || a.Min() != time.Millisecond || a.Max() != time.Second {
+		t.Errorf("unexpected histogram: count %d, min %s, max %s", a.Count(), a.Min(), a.Max())
+	}
+	// values are reported as the highest one of their bucket
+	if p := a.Percentile(99); p < time.Millisecond || p > 1001*time.Microsecond {
+		t.Errorf("unexpected p99: %s", p)
+	}
+	if p := a.Percentile(99.9); p != time.Second {
+		t.Errorf("unexpected p99.9: %s", p)
+	}
+}
+
+func TestHistogram_JSON(t *testing.T) {
+	h := NewHistogram()
+	for _, d := range []time.Duration{0, 17, 3 * time.Millisecond, 3 * time.Millisecond, 2 * time.Hour} {
+		h.Record(d)
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	decoded := &Histogram{}
+	if err := json.Unmarshal(b, decoded); err != nil {
+		t.Error(err)
+		return
+	}
+	if decoded.Count() != h.Count() || decoded.Min() != h.Min() || decoded.Max() != h.Max() {
+		t.Errorf("unexpected histogram: %s", string(b))
+	}
+	for _, p := range []float64{1, 20, 50, 70, 99} {
+		if decoded.Percentile(p) != h.Percentile(p) {
+			t.Errorf("unexpected p%g: %s", p, decoded.Percentile(p))
+		}
+	}
+	if err := json.Unmarshal([]byte(`"AQ=="`), decoded); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestHistogram_RecordCorrected(t *testing.T) {
+	h := NewHistogram()
+	for i := 0; i < 99; i++ {
+		h.RecordCorrected(time.Millisecond, 10*time.Millisecond)
+	}
+	// a stall of 1s hides the 99 requests the worker should have sent
+	h.RecordCorrected(time.Second, 10*time.Millisecond)
+
+	if h.Count() != 199 {
+		t.Errorf("unexpected number of values: %d", h.Count())
+	}
+	if p := h.Percentile(75); p < 500*time.Millisecond || p > 520*time.Millisecond {
+		t.Errorf("unexpected p75: %s", p)
+	}
+}
+
+func TestReport_Correct(t *testing.T) {
+	r := Report{Samples: []Sample{
+		{Latency: 0.001},
+		{Latency: 0.035},
+		{Latency: 5, Error: "timeout"},
+	}}
+	r.Correct(10 * time.Millisecond)
+	if r.ExpectedInterval != 10*time.Millisecond {
+		t.Errorf("unexpected interval: %s", r.ExpectedInterval)
+	}
+	// 1ms, 35ms and the missing 25ms, 15ms
+	if r.Corrected.Count() != 4 {
+		t.Errorf("unexpected number of values: %d", r.Corrected.Count())
+	}
+
+	hey := Report{}
+	hey.Lats = []float64{0.001, 0.035}
+	hey.Correct(10 * time.Millisecond)
+	if hey.Corrected.Count() != 4 {
+		t.Errorf("unexpected number of values: %d", hey.Corrected.Count())
+	}
+	if cdf := hey.CorrectedCDF(); len(cdf.Values) != len(hey.CDF().Values) {
+		t.Errorf("unexpected corrected cdf: %+v", cdf)
+	}
+}
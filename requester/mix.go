@@ -0,0 +1,139 @@
+package requester
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Mix is a set of weighted requests. Every request sent picks one of them with
+// a probability proportional to its weight.
+type Mix struct {
+	entries []mixEntry
+	total   int
+	feeder  *feeder
+}
+
+type mixEntry struct {
+	name    string
+	weight  int
+	request *http.Request
+	body    []byte
+	// tmpl renders the requests of the definitions with templates
+	tmpl *requestTemplate
+}
+
+// NewMix validates the definitions and builds the requests of the mix.
+// Requests without a weight get a weight of 1 and the ones without a name are
+// named after their method and URL. Requests with templates are rendered every
+// time they are sent.
+func NewMix(reqs []Request) (*Mix, error) {
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("the mix has no requests")
+	}
+	m := &Mix{entries: make([]mixEntry, 0, len(reqs))}
+	funcs := newFuncMap()
+	names := map[string]struct{}{}
+	for i, r := range reqs {
+		if r.Weight < 0 {
+			return nil, fmt.Errorf("request #%d: negative weight", i)
+		}
+		e := mixEntry{
+			name:   r.Label(),
+			weight: max(r.Weight, 1),
+			body:   []byte(r.Body),
+		}
+		var err error
+		if r.IsTemplate() {
+			e.tmpl, err = newRequestTemplate(r, funcs)
+		} else {
+			e.request, err = r.HTTPRequest()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("request #%d: %s", i, err.Error())
+		}
+		if _, ok := names[e.name]; ok {
+			return nil, fmt.Errorf("request #%d: duplicated name %q", i, e.name)
+		}
+		names[e.name] = struct{}{}
+		m.entries = append(m.entries, e)
+		m.total += e.weight
+	}
+	return m, nil
+}
+
+// singleMix wraps a single request into a mix. It returns a nil generator if
+// there is no request.
+func singleMix(req *http.Request, body []byte) generator {
+	if req == nil {
+		return nil
+	}
+	return &Mix{
+		entries: []mixEntry{{weight: 1, request: req, body: body}},
+		total:   1,
+	}
+}
+
+func (m *Mix) pick() mixEntry {
+	if len(m.entries) == 1 {
+		return m.entries[0]
+	}
+	n := rand.IntN(m.total)
+	for _, e := range m.entries {
+		if n < e.weight {
+			return e
+		}
+		n -= e.weight
+	}
+	return m.entries[len(m.entries)-1]
+}
+
+func (m *Mix) names() []string {
+	res := make([]string, len(m.entries))
+	for i, e := range m.entries {
+		res[i] = e.name
+	}
+	return res
+}
+
+func (m *Mix) reset() {
+	m.feeder.reset()
+}
+
+func (m *Mix) newClient(conns int, timeout time.Duration) *http.Client {
+	// the TLS server name can only be forced when all the requests share it
+	if len(m.entries) == 1 && m.entries[0].request != nil {
+		return newClient(m.entries[0].request, conns, timeout)
+	}
+	return newClient(nil, conns, timeout)
+}
+
+func (m *Mix) newUser(c *http.Client) *user {
+	return &user{client: c}
+}
+
+// iterate sends a single request picked from the mix
+func (m *Mix) iterate(ctx context.Context, u *user, start time.Time) ([]sample, bool) {
+	vars, ok := m.feeder.vars()
+	if !ok {
+		return nil, false
+	}
+	e := m.pick()
+	req := e.request
+	if e.tmpl != nil {
+		var err error
+		if req, err = e.tmpl.request(vars); err != nil {
+			return []sample{{name: e.name, err: err}}, true
+		}
+	} else {
+		req = cloneRequest(req, e.body)
+	}
+	s := doRequest(ctx, u.client, req, start)
+	if s.err != nil && ctx.Err() != nil {
+		return nil, true
+	}
+	s.name = e.name
+	return []sample{s}, true
+}
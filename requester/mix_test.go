@@ -0,0 +1,101 @@
+package requester
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewMix(t *testing.T) {
+	m, err := NewMix([]Request{
+		{Name: "a", URL: "http://example.com/a", Weight: 3},
+		{Method: "POST", URL: "http://example.com/b"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if m.total != 4 {
+		t.Errorf("unexpected total weight: %d", m.total)
+	}
+	if names := m.names(); names[0] != "a" || names[1] != "POST http://example.com/b" {
+		t.Errorf("unexpected names: %v", names)
+	}
+
+	picks := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		picks[m.pick().name]++
+	}
+	if picks["a"] < 2700 || picks["a"] > 3300 {
+		t.Errorf("unexpected distribution: %v", picks)
+	}
+
+	for _, reqs := range [][]Request{
+		{},
+		{{URL: "http://example.com", Weight: -1}},
+		{{URL: "http://example.com"}, {URL: "http://example.com"}},
+		{{Method: "bad method", URL: "http://example.com"}},
+	} {
+		if _, err := NewMix(reqs); err == nil {
+			t.Errorf("error expected for %+v", reqs)
+		}
+	}
+}
+
+func TestNewWorkloadJSON_mix(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer ts.Close()
+
+	r, err := NewWorkloadJSON(Workload{Mix: []Request{
+		{Name: "list", URL: ts.URL, Weight: 7},
+		{Name: "create", Method: "POST", URL: ts.URL, Body: "{}", Weight: 3},
+	}}, 300*time.Millisecond)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	report := Report{}
+	if err := json.NewDecoder(r.Run(context.Background(), 4)).Decode(&report); err != nil {
+		t.Error(err)
+		return
+	}
+	if report.C != 4 {
+		t.Errorf("unexpected concurrency: %d", report.C)
+	}
+	if report.NumRes == 0 || len(report.ErrorDist) != 0 {
+		t.Errorf("unexpected responses: %d %v", report.NumRes, report.ErrorDist)
+	}
+	if len(report.Breakdown) != 2 {
+		t.Errorf("unexpected breakdown: %+v", report.Breakdown)
+		return
+	}
+	list, create := report.Breakdown[0], report.Breakdown[1]
+	if list.Name != "list" || create.Name != "create" {
+		t.Errorf("unexpected breakdown: %+v", report.Breakdown)
+	}
+	if list.NumRes+create.NumRes != report.NumRes {
+		t.Errorf("the breakdown does not match the aggregate: %d + %d != %d", list.NumRes, create.NumRes, report.NumRes)
+	}
+	if list.NumRes <= create.NumRes {
+		t.Errorf("unexpected mix: %d list, %d create", list.NumRes, create.NumRes)
+	}
+	if create.StatusCodeDist[http.StatusCreated] != int(create.NumRes) {
+		t.Errorf("unexpected status codes: %v", create.StatusCodeDist)
+	}
+
+	if err := json.NewDecoder(r.(BoundedRequester).RunN(context.Background(), 4, 10)).Decode(&report); err != nil {
+		t.Error(err)
+		return
+	}
+	if report.NumRes != 10 {
+		t.Errorf("unexpected number of responses: %d", report.NumRes)
+	}
+}
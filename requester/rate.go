@@ -0,0 +1,159 @@
+package requester
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// lateThreshold is the delay after its scheduled time from which a request is
+// considered late
+const lateThreshold = 10 * time.Millisecond
+
+// defaultMaxInFlight is the number of requests allowed to be waiting for a
+// response before new arrivals get dropped, per each rps of the target rate
+const defaultMaxInFlight = 10
+
+// NewRateJSON returns a Requester implementing an open model: the c argument of
+// its Run method is the target arrival rate (requests per second), sustained
+// no matter how long the responses take.
+func NewRateJSON(req *http.Request, timeout time.Duration) Requester {
+	body := new(bytes.Buffer)
+	if req != nil && req.Body != nil {
+		body.ReadFrom(req.Body)
+		req.Body.Close()
+	}
+	return rateRequester{
+		Generator: singleMix(req, body.Bytes()),
+		Timeout:   timeout,
+	}
+}
+
+type rateRequester struct {
+	Generator   generator
+	Timeout     time.Duration
+	MaxInFlight int
+}
+
+func (r rateRequester) Run(ctx context.Context, rate int) io.Reader {
+	return r.RunFor(ctx, rate, r.Timeout)
+}
+
+// RunFor stops the arrivals after d (or the timeout, if shorter), waiting for
+// the responses of the requests in flight
+func (r rateRequester) RunFor(ctx context.Context, rate int, d time.Duration) io.Reader {
+	if d <= 0 || d > r.Timeout {
+		d = r.Timeout
+	}
+	localCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	log.Println("starting the load test")
+	report := r.run(ctx, localCtx, rate, 0)
+	log.Println("load test ended")
+
+	buf := new(bytes.Buffer)
+	json.NewEncoder(buf).Encode(report)
+	return buf
+}
+
+// RunN stops after n arrivals or once the timeout is reached
+func (r rateRequester) RunN(ctx context.Context, rate, n int) io.Reader {
+	localCtx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	report := r.run(ctx, localCtx, rate, n)
+
+	buf := new(bytes.Buffer)
+	json.NewEncoder(buf).Encode(report)
+	return buf
+}
+
+// run dispatches the requests until the localCtx is done or, if limit is
+// positive, after limit arrivals. In-flight requests are only interrupted if
+// the parent ctx is cancelled.
+func (r rateRequester) run(ctx, localCtx context.Context, rate, limit int) Report {
+	report := Report{Rate: rate}
+	if rate <= 0 || r.Generator == nil {
+		return report
+	}
+	r.Generator.reset()
+
+	maxInFlight := r.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = defaultMaxInFlight * rate
+	}
+
+	client := r.Generator.newClient(maxInFlight, r.Timeout)
+	interval := time.Second / time.Duration(rate)
+	inFlight := make(chan struct{}, maxInFlight)
+	results := make(chan sample, maxInFlight)
+	samples := []sample{}
+	collected := make(chan struct{})
+	go func() {
+		for s := range results {
+			samples = append(samples, s)
+		}
+		close(collected)
+	}()
+
+	wg := new(sync.WaitGroup)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	start := time.Now()
+	for i := 0; limit <= 0 || i < limit; i++ {
+		scheduled := start.Add(time.Duration(i) * interval)
+		if wait := time.Until(scheduled); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-localCtx.Done():
+			case <-timer.C:
+			}
+		}
+		if localCtx.Err() != nil {
+			break
+		}
+
+		if time.Since(scheduled) > lateThreshold {
+			report.Late++
+		}
+
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			report.Dropped++
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ss, _ := r.Generator.iterate(ctx, r.Generator.newUser(client), start)
+			<-inFlight
+			for _, s := range ss {
+				results <- s
+			}
+		}()
+	}
+
+	wg.Wait()
+	total := time.Since(start)
+	close(results)
+	<-collected
+
+	report.Report = aggregate(samples, total)
+	report.Start = start
+	report.Samples = rawSamples(samples)
+	report.Latencies = latencyHistogram(samples)
+	if names := r.Generator.names(); len(names) > 1 {
+		report.Breakdown = breakdown(samples, total, names)
+	}
+	return report
+}
@@ -0,0 +1,85 @@
+package requester
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewRateJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	r := NewRateJSON(req, 500*time.Millisecond)
+
+	report := Report{}
+	if err := json.NewDecoder(r.Run(context.Background(), 100)).Decode(&report); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if report.Rate != 100 {
+		t.Errorf("unexpected rate: %d", report.Rate)
+	}
+	if report.NumRes < 40 || report.NumRes > 60 {
+		t.Errorf("unexpected number of responses: %d", report.NumRes)
+	}
+	if report.StatusCodeDist[http.StatusTeapot] != int(report.NumRes) {
+		t.Errorf("unexpected status codes: %v", report.StatusCodeDist)
+	}
+	if report.Dropped != 0 {
+		t.Errorf("unexpected dropped requests: %d", report.Dropped)
+	}
+	if len(report.LatencyDistribution) != len(percentiles) {
+		t.Errorf("unexpected latency distribution: %v", report.LatencyDistribution)
+	}
+}
+
+func TestNewRateJSON_dropped(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	r := rateRequester{Generator: singleMix(req, nil), Timeout: 300 * time.Millisecond, MaxInFlight: 5}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	report := r.run(context.Background(), ctx, 100, 0)
+
+	if report.Dropped < 15 {
+		t.Errorf("unexpected dropped requests: %d", report.Dropped)
+	}
+	if report.NumRes != 5 {
+		t.Errorf("unexpected number of responses: %d", report.NumRes)
+	}
+	if len(report.ErrorDist) == 0 {
+		t.Errorf("the requests should have timed out")
+	}
+}
+
+func TestRateRequester_RunN(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	r := NewRateJSON(req, time.Second).(BoundedRequester)
+
+	report := Report{}
+	if err := json.NewDecoder(r.RunN(context.Background(), 100, 7)).Decode(&report); err != nil {
+		t.Error(err)
+		return
+	}
+	if report.NumRes != 7 {
+		t.Errorf("unexpected number of responses: %d", report.NumRes)
+	}
+}
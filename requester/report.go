@@ -1,6 +1,7 @@
 package requester
 
 import (
+	"math"
 	"time"
 
 	hey "github.com/rakyll/hey/requester"
@@ -8,14 +9,69 @@ import (
 
 type Report struct {
 	hey.Report
-	C             int
-	URL           string
+	C   int
+	URL string
+	// Rate is the target arrival rate (rps) of the steps run in rate mode
+	Rate int `json:",omitempty"`
+	// Dropped counts the arrivals not sent because too many requests were
+	// already waiting for a response
+	Dropped int64 `json:",omitempty"`
+	// Late counts the requests sent behind their schedule
+	Late int64 `json:",omitempty"`
+	// Breakdown summarizes the results of every request of a mix
+	Breakdown []RequestSummary `json:",omitempty"`
+	// Start is the time the step started. The offsets of the samples are
+	// relative to it
+	Start time.Time `json:",omitempty"`
+	// Samples holds the raw data of every request sent by the native engine
+	Samples []Sample `json:",omitempty"`
+	// TimeSeries summarizes the samples per interval
+	TimeSeries []Bucket `json:",omitempty"`
+	// Latencies records the latencies of the successful requests of the
+	// native engine. The reports of hey keep them in the Lats list instead
+	Latencies *Histogram `json:",omitempty"`
+	// Corrected records the same latencies, corrected for coordinated
+	// omission with the ExpectedInterval between requests
+	Corrected        *Histogram    `json:",omitempty"`
+	ExpectedInterval time.Duration `json:",omitempty"`
+
 	pdf           Sequence
 	pdfCalculated bool
 	cdf           Sequence
 	cdfCalculated bool
 }
 
+// Sample is the data collected for a single request. The offset and the
+// latencies are in seconds.
+type Sample struct {
+	Name    string `json:",omitempty"`
+	Offset  float64
+	Latency float64
+	Conn    float64
+	DNS     float64
+	Req     float64
+	Delay   float64
+	Res     float64
+	Status  int    `json:",omitempty"`
+	Bytes   int64  `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+// RequestSummary describes the results of one of the requests of a mix.
+// Latencies are in seconds.
+type RequestSummary struct {
+	Name           string
+	NumRes         int64
+	Rps            float64
+	Average        float64
+	Fastest        float64
+	Slowest        float64
+	P50            float64
+	P99            float64
+	Errors         int
+	StatusCodeDist map[int]int `json:",omitempty"`
+}
+
 type Sequence struct {
 	Labels []time.Duration
 	Values []float64
@@ -25,6 +81,11 @@ func (r *Report) PDF() Sequence {
 	if r.pdfCalculated {
 		return r.pdf
 	}
+	if r.Latencies.Count() > 0 {
+		r.pdf, r.cdf = histogramSequences(r.Latencies, histogramMarks)
+		r.pdfCalculated, r.cdfCalculated = true, true
+		return r.pdf
+	}
 	pdf := Sequence{
 		Labels: make([]time.Duration, len(r.Report.Histogram)),
 		Values: make([]float64, len(r.Report.Histogram)),
@@ -43,6 +104,9 @@ func (r *Report) CDF() Sequence {
 		return r.cdf
 	}
 	pdf := r.PDF()
+	if r.cdfCalculated {
+		return r.cdf
+	}
 	cdf := Sequence{
 		Labels: pdf.Labels,
 		Values: make([]float64, len(pdf.Values)),
@@ -58,3 +122,124 @@ func (r *Report) CDF() Sequence {
 	r.cdf = cdf
 	return cdf
 }
+
+// histogramMarks is the number of points of the distributions built from the
+// histogram of the latencies
+const histogramMarks = 50
+
+// histogramSequences splits the range of the latencies in the given number of
+// marks, returning the share of the latencies between every mark and the
+// previous one (pdf) and the share of the ones below every mark (cdf)
+func histogramSequences(h *Histogram, marks int) (Sequence, Sequence) {
+	pdf := Sequence{Labels: make([]time.Duration, marks+1), Values: make([]float64, marks+1)}
+	cdf := Sequence{Labels: pdf.Labels, Values: make([]float64, marks+1)}
+	fastest, slowest := h.Min(), h.Max()
+	size := float64(slowest-fastest) / float64(marks)
+	total := float64(h.Count())
+	prev := 0.0
+	for i := range pdf.Labels {
+		mark := fastest + time.Duration(size*float64(i))
+		if i == marks {
+			mark = slowest
+		}
+		pdf.Labels[i] = mark
+		cdf.Values[i] = float64(h.CountBelow(mark)) / total
+		pdf.Values[i] = cdf.Values[i] - prev
+		prev = cdf.Values[i]
+	}
+	return pdf, cdf
+}
+
+// CorrectedCDF returns the share of the corrected latencies below every mark
+// of the CDF of the raw ones, so both can be charted together
+func (r *Report) CorrectedCDF() Sequence {
+	cdf := r.CDF()
+	res := Sequence{Labels: cdf.Labels, Values: make([]float64, len(cdf.Labels))}
+	if r.Corrected.Count() == 0 {
+		return res
+	}
+	for i, mark := range cdf.Labels {
+		res.Values[i] = float64(r.Corrected.CountBelow(mark)) / float64(r.Corrected.Count())
+	}
+	return res
+}
+
+// Correct records the latencies of the successful requests into the
+// Corrected histogram, compensating the coordinated omission of the closed
+// model: a worker waiting for a slow response does not send the requests it
+// was expected to send every interval.
+func (r *Report) Correct(interval time.Duration) {
+	h := NewHistogram()
+	if len(r.Samples) > 0 {
+		for _, s := range r.Samples {
+			if s.Error == "" {
+				h.RecordCorrected(time.Duration(s.Latency*float64(time.Second)), interval)
+			}
+		}
+	} else {
+		for _, l := range r.Lats {
+			h.RecordCorrected(time.Duration(l*float64(time.Second)), interval)
+		}
+	}
+	r.Corrected = h
+	r.ExpectedInterval = interval
+}
+
+// CorrectedPercentile returns the latency (in seconds) of the given percentile
+// of the corrected latencies
+func (r *Report) CorrectedPercentile(p float64) float64 {
+	return r.Corrected.Percentile(p).Seconds()
+}
+
+// HasLatencies checks if the report recorded the latency of any request
+func (r *Report) HasLatencies() bool {
+	return len(r.Lats) > 0 || r.Latencies.Count() > 0
+}
+
+// LatencyHistogram returns the histogram of the latencies of the successful
+// requests. For the reports without one, it is built from their latencies.
+func (r *Report) LatencyHistogram() *Histogram {
+	if r.Latencies != nil {
+		return r.Latencies
+	}
+	h := NewHistogram()
+	for _, l := range r.Lats {
+		h.Record(time.Duration(l * float64(time.Second)))
+	}
+	return h
+}
+
+// Percentile returns the latency (in seconds) of the given percentile of the
+// successful requests
+func (r *Report) Percentile(p float64) float64 {
+	if len(r.Lats) == 0 {
+		return r.Latencies.Percentile(p).Seconds()
+	}
+	lats := sortedCopy(r.Lats)
+	idx := int(math.Ceil(float64(len(lats))*p/100)) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(lats) {
+		idx = len(lats) - 1
+	}
+	return lats[idx]
+}
+
+// ErrorRatio returns the share of the requests failing or getting a server
+// error (5xx) as response
+func (r *Report) ErrorRatio() float64 {
+	if r.NumRes == 0 {
+		return 0
+	}
+	errs := 0
+	for _, n := range r.ErrorDist {
+		errs += n
+	}
+	for code, n := range r.StatusCodeDist {
+		if code >= 500 {
+			errs += n
+		}
+	}
+	return float64(errs) / float64(r.NumRes)
+}
@@ -0,0 +1,47 @@
+package requester
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Request is the serializable definition of the request to send
+type Request struct {
+	// Name identifies the request in the results of a mix
+	Name   string `json:",omitempty"`
+	Method string
+	URL    string
+	Header http.Header `json:",omitempty"`
+	Body   string      `json:",omitempty"`
+	// Weight is the relative frequency of the request in a mix
+	Weight int `json:",omitempty"`
+}
+
+// Label returns the name of the request or, if it is empty, its method and URL
+func (r Request) Label() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	method := r.Method
+	if method == "" {
+		method = "GET"
+	}
+	return method + " " + r.URL
+}
+
+// HTTPRequest builds a new http.Request from the definition
+func (r Request) HTTPRequest() (*http.Request, error) {
+	method := r.Method
+	if method == "" {
+		method = "GET"
+	}
+	req, err := http.NewRequest(method, r.URL, strings.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	return req, nil
+}
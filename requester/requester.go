@@ -39,6 +39,21 @@ type Requester interface {
 	Run(ctx context.Context, c int) io.Reader
 }
 
+// BoundedRequester is implemented by the requesters able to stop after sending
+// a given number of requests
+type BoundedRequester interface {
+	Requester
+	RunN(ctx context.Context, c, n int) io.Reader
+}
+
+// TimedRequester is implemented by the requesters able to stop sending new
+// requests after a given time while waiting for the ones in flight, which are
+// only interrupted if the ctx is cancelled
+type TimedRequester interface {
+	Requester
+	RunFor(ctx context.Context, c int, d time.Duration) io.Reader
+}
+
 type requester struct {
 	Request *http.Request
 	Body    []byte
@@ -48,10 +63,22 @@ type requester struct {
 }
 
 func (r requester) Run(ctx context.Context, c int) io.Reader {
+	return r.run(ctx, c, r.N)
+}
+
+// RunN stops after sending n requests or once the timeout is reached
+func (r requester) RunN(ctx context.Context, c, n int) io.Reader {
+	if c > n {
+		c = n
+	}
+	return r.run(ctx, c, n)
+}
+
+func (r requester) run(ctx context.Context, c, n int) io.Reader {
 	buf := new(bytes.Buffer)
 
 	work := hey.Work{
-		N:           r.N,
+		N:           n,
 		C:           c,
 		Timeout:     int(r.Timeout / time.Second),
 		RequestBody: r.Body,
@@ -66,6 +93,8 @@ func (r requester) Run(ctx context.Context, c int) io.Reader {
 	localCtx, cancel := context.WithTimeout(ctx, r.Timeout)
 	defer cancel()
 
+	// the stop channel must exist before a cancellation can call work.Stop
+	work.Init()
 	go func(localCtx context.Context, cancelWorkFunc func()) {
 		<-localCtx.Done()
 		cancelWorkFunc()
@@ -0,0 +1,140 @@
+package requester
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"time"
+)
+
+// ScenarioStep is a request of a user journey. Its URL, headers and body are
+// templates rendered with the values extracted by the previous steps of the
+// same iteration (e.g. "Bearer {{ .token }}").
+type ScenarioStep struct {
+	Request
+	// Extract lists the values to capture from the response
+	Extract []Extractor `json:",omitempty"`
+	// Think is the pause of the virtual user after the step
+	Think Duration `json:",omitempty"`
+}
+
+// scenario sends its steps one after the other, keeping a cookie jar per
+// virtual user
+type scenario struct {
+	steps  []scenarioStep
+	feeder *feeder
+}
+
+type scenarioStep struct {
+	*requestTemplate
+	name    string
+	extract []extractor
+	think   time.Duration
+}
+
+func newScenario(steps []ScenarioStep) (*scenario, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("the scenario has no steps")
+	}
+	s := &scenario{steps: make([]scenarioStep, len(steps))}
+	funcs := newFuncMap()
+	names := map[string]struct{}{}
+	for i, step := range steps {
+		st := scenarioStep{
+			name:  step.Label(),
+			think: time.Duration(step.Think),
+		}
+		if _, ok := names[st.name]; ok {
+			return nil, fmt.Errorf("step #%d: duplicated name %q", i, st.name)
+		}
+		names[st.name] = struct{}{}
+
+		tmpl, err := newRequestTemplate(step.Request, funcs)
+		if err != nil {
+			return nil, fmt.Errorf("step #%d: %s", i, err.Error())
+		}
+		st.requestTemplate = tmpl
+		for _, e := range step.Extract {
+			ex, err := e.compile()
+			if err != nil {
+				return nil, fmt.Errorf("step #%d: %s", i, err.Error())
+			}
+			st.extract = append(st.extract, ex)
+		}
+		s.steps[i] = st
+	}
+	return s, nil
+}
+
+func (s *scenario) names() []string {
+	res := make([]string, len(s.steps))
+	for i, step := range s.steps {
+		res[i] = step.name
+	}
+	return res
+}
+
+func (s *scenario) reset() {
+	s.feeder.reset()
+}
+
+func (s *scenario) newClient(conns int, timeout time.Duration) *http.Client {
+	return newClient(nil, conns, timeout)
+}
+
+// newUser returns a virtual user with its own cookie jar
+func (s *scenario) newUser(c *http.Client) *user {
+	jar, _ := cookiejar.New(nil)
+	return &user{client: &http.Client{Transport: c.Transport, Timeout: c.Timeout, Jar: jar}}
+}
+
+// iterate runs the steps of the scenario, with the variables of the next row of
+// the feeder, if any. The iteration is aborted if a request fails or a value
+// can not be extracted.
+func (s *scenario) iterate(ctx context.Context, u *user, start time.Time) ([]sample, bool) {
+	vars, ok := s.feeder.vars()
+	if !ok {
+		return nil, false
+	}
+	samples := make([]sample, 0, len(s.steps))
+	for _, step := range s.steps {
+		req, err := step.request(vars)
+		if err != nil {
+			samples = append(samples, sample{name: step.name, err: err})
+			return samples, true
+		}
+
+		res := &response{}
+		smp := doCapture(ctx, u.client, req, start, res)
+		if smp.err != nil && ctx.Err() != nil {
+			return samples, true
+		}
+		smp.name = step.name
+		if smp.err == nil {
+			for _, e := range step.extract {
+				v, err := e.extract(res)
+				if err != nil {
+					smp.err = err
+					break
+				}
+				vars[e.name] = v
+			}
+		}
+		samples = append(samples, smp)
+		if smp.err != nil {
+			return samples, true
+		}
+
+		if step.think > 0 {
+			t := time.NewTimer(step.think)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return samples, true
+			case <-t.C:
+			}
+		}
+	}
+	return samples, true
+}
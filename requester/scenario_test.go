@@ -0,0 +1,170 @@
+package requester
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewWorkloadJSON_scenario(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1"})
+		w.Write([]byte(`{"data":{"token":"abc123","items":[{"id":42}]}}`))
+	})
+	mux.HandleFunc("/items/42", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if c, err := r.Cookie("sid"); err != nil || c.Value != "s1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	r, err := NewWorkloadJSON(Workload{Scenario: []ScenarioStep{
+		{
+			Request: Request{Name: "login", Method: "POST", URL: ts.URL + "/login"},
+			Extract: []Extractor{
+				{Var: "token", From: ExtractJSON, Expr: "data.token"},
+				{Var: "id", From: ExtractJSON, Expr: "$.data.items.0.id"},
+			},
+			Think: Duration(time.Millisecond),
+		},
+		{
+			Request: Request{
+				Name:   "item",
+				URL:    ts.URL + "/items/{{ .id }}",
+				Header: http.Header{"Authorization": []string{"Bearer {{ .token }}"}},
+			},
+		},
+	}}, 300*time.Millisecond)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	report := Report{}
+	if err := json.NewDecoder(r.Run(context.Background(), 2)).Decode(&report); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(report.Breakdown) != 2 {
+		t.Errorf("unexpected breakdown: %+v", report.Breakdown)
+		return
+	}
+	login, item := report.Breakdown[0], report.Breakdown[1]
+	if login.NumRes == 0 || item.NumRes == 0 {
+		t.Errorf("unexpected responses: %+v", report.Breakdown)
+	}
+	if item.StatusCodeDist[http.StatusNoContent] != int(item.NumRes) {
+		t.Errorf("unexpected status codes: %v", item.StatusCodeDist)
+	}
+	if len(report.ErrorDist) != 0 {
+		t.Errorf("unexpected errors: %v", report.ErrorDist)
+	}
+}
+
+func TestNewWorkloadJSON_scenarioExtractionError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	r, err := NewRateWorkloadJSON(Workload{Scenario: []ScenarioStep{
+		{
+			Request: Request{Name: "login", URL: ts.URL},
+			Extract: []Extractor{{Var: "token", From: ExtractJSON, Expr: "token"}},
+		},
+		{Request: Request{Name: "next", URL: ts.URL + "/{{ .token }}"}},
+	}}, 200*time.Millisecond)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	report := Report{}
+	if err := json.NewDecoder(r.Run(context.Background(), 50)).Decode(&report); err != nil {
+		t.Error(err)
+		return
+	}
+	if report.ErrorDist[`extracting token: json "token" not found`] != int(report.NumRes) {
+		t.Errorf("unexpected errors: %v", report.ErrorDist)
+	}
+	if report.Breakdown[1].NumRes != 0 {
+		t.Errorf("the iterations should have been aborted: %+v", report.Breakdown)
+	}
+}
+
+func TestWorkload_Validate(t *testing.T) {
+	for _, w := range []Workload{
+		{},
+		{Mix: []Request{{URL: "http://example.com"}}, Scenario: []ScenarioStep{{Request: Request{URL: "http://example.com"}}}},
+		{Scenario: []ScenarioStep{{Request: Request{URL: "http://example.com/{{ .id"}}}},
+		{Scenario: []ScenarioStep{{Request: Request{URL: "http://example.com"}, Extract: []Extractor{{Var: "x", From: "xpath"}}}}},
+		{Scenario: []ScenarioStep{{Request: Request{URL: "http://example.com"}, Extract: []Extractor{{Var: "x", From: ExtractRegex, Expr: "("}}}}},
+	} {
+		if err := w.Validate(); err == nil {
+			t.Errorf("error expected for %+v", w)
+		}
+	}
+}
+
+func Test_extractor(t *testing.T) {
+	res := &response{
+		header: http.Header{
+			"Location":   []string{"/items/7"},
+			"Set-Cookie": []string{"sid=s1; Path=/"},
+		},
+		body: []byte(`{"ok":true,"n":1.5,"list":["a",{"b":"c"}],"obj":{"k":1}} id=99`),
+	}
+	for _, tc := range []struct {
+		extractor Extractor
+		value     string
+	}{
+		{Extractor{Var: "v", From: ExtractHeader, Expr: "Location"}, "/items/7"},
+		{Extractor{Var: "v", From: ExtractCookie, Expr: "sid"}, "s1"},
+		{Extractor{Var: "v", From: ExtractRegex, Expr: `id=(\d+)`}, "99"},
+		{Extractor{Var: "v", From: ExtractRegex, Expr: `id=\d+`}, "id=99"},
+	} {
+		ex, err := tc.extractor.compile()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		v, err := ex.extract(res)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if v != tc.value {
+			t.Errorf("unexpected value for %+v: %q", tc.extractor, v)
+		}
+	}
+
+	var doc interface{}
+	json.Unmarshal([]byte(`{"ok":true,"n":1.5,"list":["a",{"b":"c"}],"obj":{"k":1}}`), &doc)
+	for path, value := range map[string]string{
+		"ok":       "true",
+		"n":        "1.5",
+		"list.0":   "a",
+		"list.1.b": "c",
+		"$.obj":    `{"k":1}`,
+	} {
+		if v, ok := jsonPath(doc, path); !ok || v != value {
+			t.Errorf("unexpected value for %s: %q", path, v)
+		}
+	}
+	for _, path := range []string{"missing", "list.2", "list.x", "ok.x"} {
+		if _, ok := jsonPath(doc, path); ok {
+			t.Errorf("the path %s should not be found", path)
+		}
+	}
+}
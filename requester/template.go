@@ -0,0 +1,161 @@
+package requester
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	mrand "math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// IsTemplate checks if the URL, the headers or the body of the request are
+// templates, so they must be rendered for every request sent
+func (r Request) IsTemplate() bool {
+	if isTemplate(r.URL) || isTemplate(r.Body) {
+		return true
+	}
+	for _, vs := range r.Header {
+		for _, v := range vs {
+			if isTemplate(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// requestTemplate renders the requests of a definition with templates
+type requestTemplate struct {
+	method string
+	url    *template.Template
+	header map[string][]*template.Template
+	body   *template.Template
+}
+
+func newRequestTemplate(r Request, funcs template.FuncMap) (*requestTemplate, error) {
+	t := &requestTemplate{method: r.Method, header: map[string][]*template.Template{}}
+	if t.method == "" {
+		t.method = "GET"
+	}
+	// validate the method and, if it is not a template, the URL
+	u := r.URL
+	if isTemplate(u) {
+		u = "/"
+	}
+	if _, err := http.NewRequest(t.method, u, nil); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if t.url, err = newTemplate(r.URL, funcs); err != nil {
+		return nil, fmt.Errorf("parsing the URL: %s", err.Error())
+	}
+	if t.body, err = newTemplate(r.Body, funcs); err != nil {
+		return nil, fmt.Errorf("parsing the body: %s", err.Error())
+	}
+	for k, vs := range r.Header {
+		for _, v := range vs {
+			tmpl, err := newTemplate(v, funcs)
+			if err != nil {
+				return nil, fmt.Errorf("parsing the header %s: %s", k, err.Error())
+			}
+			t.header[k] = append(t.header[k], tmpl)
+		}
+	}
+	return t, nil
+}
+
+// request renders a new request with the given variables
+func (t *requestTemplate) request(vars map[string]string) (*http.Request, error) {
+	u, err := render(t.url, vars)
+	if err != nil {
+		return nil, fmt.Errorf("rendering the URL: %s", err.Error())
+	}
+	body, err := render(t.body, vars)
+	if err != nil {
+		return nil, fmt.Errorf("rendering the body: %s", err.Error())
+	}
+	req, err := http.NewRequest(t.method, u, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, tmpls := range t.header {
+		for _, tmpl := range tmpls {
+			v, err := render(tmpl, vars)
+			if err != nil {
+				return nil, fmt.Errorf("rendering the header %s: %s", k, err.Error())
+			}
+			req.Header.Add(k, v)
+		}
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	return req, nil
+}
+
+func newTemplate(txt string, funcs template.FuncMap) (*template.Template, error) {
+	return template.New("").Funcs(funcs).Option("missingkey=error").Parse(txt)
+}
+
+func render(tmpl *template.Template, vars map[string]string) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+const randomChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// newFuncMap returns the helpers available to the templates. The sequence
+// counter is shared by all the requests of the same workload.
+func newFuncMap() template.FuncMap {
+	var seq int64
+	return template.FuncMap{
+		// uuid returns a random (version 4) UUID
+		"uuid": func() string {
+			b := make([]byte, 16)
+			rand.Read(b)
+			b[6] = (b[6] & 0x0f) | 0x40
+			b[8] = (b[8] & 0x3f) | 0x80
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+		},
+		// seq returns the next value of the counter, starting at 1
+		"seq": func() int64 {
+			return atomic.AddInt64(&seq, 1)
+		},
+		// randInt returns a random number in [min, max]
+		"randInt": func(min, max int) int {
+			if max <= min {
+				return min
+			}
+			return min + mrand.IntN(max-min+1)
+		},
+		// randString returns a random alphanumeric string of the given length
+		"randString": func(n int) string {
+			b := make([]byte, n)
+			for i := range b {
+				b[i] = randomChars[mrand.IntN(len(randomChars))]
+			}
+			return string(b)
+		},
+		// now returns the current time
+		"now": time.Now,
+		// pick returns one of its arguments at random
+		"pick": func(values ...interface{}) interface{} {
+			if len(values) == 0 {
+				return ""
+			}
+			return values[mrand.IntN(len(values))]
+		},
+	}
+}
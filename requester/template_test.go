@@ -0,0 +1,118 @@
+package requester
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequest_IsTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		req      Request
+		expected bool
+	}{
+		{Request{URL: "http://example.com"}, false},
+		{Request{URL: "http://example.com/{{ seq }}"}, true},
+		{Request{URL: "http://example.com", Body: `{"id":"{{ uuid }}"}`}, true},
+		{Request{URL: "http://example.com", Header: http.Header{"X-Id": []string{"{{ seq }}"}}}, true},
+	} {
+		if tc.req.IsTemplate() != tc.expected {
+			t.Errorf("unexpected result for %+v", tc.req)
+		}
+	}
+}
+
+func Test_requestTemplate(t *testing.T) {
+	tmpl, err := newRequestTemplate(Request{
+		Method: "POST",
+		URL:    "http://example.com/items/{{ seq }}?q={{ pick \"a\" \"b\" }}",
+		Header: http.Header{"X-Request-Id": []string{"{{ uuid }}"}},
+		Body:   `{"n":{{ randInt 5 7 }},"s":"{{ randString 8 }}","ts":{{ now.Unix }},"u":"{{ .user }}"}`,
+	}, newFuncMap())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]struct{}{}
+	for i := 1; i <= 3; i++ {
+		req, err := tmpl.request(map[string]string{"user": "demo"})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if req.Method != "POST" || req.URL.Path != "/items/"+strconv.Itoa(i) {
+			t.Errorf("unexpected request: %s %s", req.Method, req.URL)
+		}
+		if q := req.URL.Query().Get("q"); q != "a" && q != "b" {
+			t.Errorf("unexpected query: %s", req.URL.RawQuery)
+		}
+		id := req.Header.Get("X-Request-Id")
+		if !uuid.MatchString(id) {
+			t.Errorf("unexpected uuid: %s", id)
+		}
+		if _, ok := seen[id]; ok {
+			t.Errorf("duplicated uuid: %s", id)
+		}
+		seen[id] = struct{}{}
+
+		b, _ := io.ReadAll(req.Body)
+		body := struct {
+			N  int
+			S  string
+			Ts int64
+			U  string
+		}{}
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Errorf("unexpected body %s: %s", string(b), err.Error())
+			continue
+		}
+		if body.N < 5 || body.N > 7 || len(body.S) != 8 || body.U != "demo" {
+			t.Errorf("unexpected body: %s", string(b))
+		}
+		if d := time.Since(time.Unix(body.Ts, 0)); d < 0 || d > time.Minute {
+			t.Errorf("unexpected timestamp: %d", body.Ts)
+		}
+	}
+
+	if _, err := tmpl.request(map[string]string{}); err == nil {
+		t.Error("error expected rendering a missing variable")
+	}
+}
+
+func TestNewWorkloadJSON_template(t *testing.T) {
+	mu := new(sync.Mutex)
+	bodies := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies[string(b)]++
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	r, err := NewWorkloadJSON(Workload{Mix: []Request{
+		{Method: "POST", URL: ts.URL, Body: `{"id":{{ seq }}}`},
+	}}, time.Second)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	report := Report{}
+	if err := json.NewDecoder(r.(BoundedRequester).RunN(context.Background(), 5, 20)).Decode(&report); err != nil {
+		t.Error(err)
+		return
+	}
+	if report.NumRes != 20 || len(bodies) != 20 {
+		t.Errorf("unexpected requests: %d responses, %d different bodies", report.NumRes, len(bodies))
+	}
+}
@@ -0,0 +1,74 @@
+package requester
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultInterval is the size of the buckets of the time series of a step
+const DefaultInterval = time.Second
+
+// Bucket summarizes the requests completed during an interval of a step. The
+// offset and the latencies are in seconds.
+type Bucket struct {
+	// Offset is the start of the interval, relative to the start of the step
+	Offset   float64
+	Requests int64
+	Rps      float64
+	Errors   int64 `json:",omitempty"`
+	Average  float64
+	P50      float64
+	P90      float64
+	P99      float64
+}
+
+// TimeSeries buckets the samples of a step by the time their responses were
+// completed. Intervals without responses are kept, so stalls are visible.
+// Latencies only account for the requests without errors, like the summary of
+// the step.
+func TimeSeries(samples []Sample, interval time.Duration) []Bucket {
+	if len(samples) == 0 {
+		return nil
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	size := interval.Seconds()
+
+	n := 0
+	for _, s := range samples {
+		if i := int(math.Floor((s.Offset + s.Latency) / size)); i >= n {
+			n = i + 1
+		}
+	}
+	res := make([]Bucket, n)
+	lats := make([][]float64, n)
+	for _, s := range samples {
+		i := int(math.Floor((s.Offset + s.Latency) / size))
+		if i < 0 {
+			i = 0
+		}
+		res[i].Requests++
+		if s.Error != "" {
+			res[i].Errors++
+			continue
+		}
+		lats[i] = append(lats[i], s.Latency)
+	}
+	for i := range res {
+		res[i].Offset = float64(i) * size
+		res[i].Rps = float64(res[i].Requests) / size
+		if len(lats[i]) == 0 {
+			continue
+		}
+		sorted := sortedCopy(lats[i])
+		for _, l := range sorted {
+			res[i].Average += l
+		}
+		res[i].Average /= float64(len(sorted))
+		res[i].P50 = percentile(sorted, 50)
+		res[i].P90 = percentile(sorted, 90)
+		res[i].P99 = percentile(sorted, 99)
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+package requester
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimeSeries(t *testing.T) {
+	samples := []Sample{
+		{Offset: 0, Latency: 0.1},
+		{Offset: 0.1, Latency: 0.3},
+		{Offset: 0.2, Latency: 0.2},
+		{Offset: 0.6, Latency: 0.2, Error: "timeout"},
+		// nothing completed between 1.5 and 2
+		{Offset: 1.2, Latency: 0.9},
+	}
+	expected := []Bucket{
+		{Offset: 0, Requests: 3, Rps: 6, Average: 0.2, P50: 0.2, P90: 0.3, P99: 0.3},
+		{Offset: 0.5, Requests: 1, Rps: 2, Errors: 1},
+		{Offset: 1, Requests: 0, Rps: 0},
+		{Offset: 1.5, Requests: 0, Rps: 0},
+		{Offset: 2, Requests: 1, Rps: 2, Average: 0.9, P50: 0.9, P90: 0.9, P99: 0.9},
+	}
+	series := TimeSeries(samples, 500*time.Millisecond)
+	if len(series) != len(expected) {
+		t.Errorf("unexpected time series: %+v", series)
+		return
+	}
+	for i, b := range series {
+		// avoid comparing the rounding errors of the average
+		if d := b.Average - expected[i].Average; d > 1e-9 || d < -1e-9 {
+			t.Errorf("unexpected average of the bucket #%d: %f", i, b.Average)
+		}
+		b.Average = expected[i].Average
+		if !reflect.DeepEqual(b, expected[i]) {
+			t.Errorf("unexpected bucket #%d: %+v", i, b)
+		}
+	}
+
+	if series := TimeSeries(nil, time.Second); series != nil {
+		t.Errorf("unexpected time series: %+v", series)
+	}
+	if series := TimeSeries(samples, 0); len(series) != 3 || series[0].Requests != 4 {
+		t.Errorf("unexpected time series with the default interval: %+v", series)
+	}
+}
@@ -0,0 +1,103 @@
+package requester
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Workload defines what the virtual users of the native engines send: a
+// weighted mix of independent requests or a scenario of chained ones
+type Workload struct {
+	Mix      []Request      `json:",omitempty"`
+	Scenario []ScenarioStep `json:",omitempty"`
+	// Feeder provides the variables of the templates
+	Feeder *Feeder `json:",omitempty"`
+}
+
+func (w Workload) generator() (generator, error) {
+	var f *feeder
+	if w.Feeder != nil {
+		var err error
+		if f, err = w.Feeder.compile(); err != nil {
+			return nil, fmt.Errorf("building the feeder: %s", err.Error())
+		}
+	}
+
+	switch {
+	case len(w.Mix) > 0 && len(w.Scenario) > 0:
+		return nil, fmt.Errorf("the workload can not define a mix and a scenario")
+	case len(w.Scenario) > 0:
+		s, err := newScenario(w.Scenario)
+		if err != nil {
+			return nil, err
+		}
+		s.feeder = f
+		return s, nil
+	}
+	m, err := NewMix(w.Mix)
+	if err != nil {
+		return nil, err
+	}
+	m.feeder = f
+	return m, nil
+}
+
+// Validate checks the workload can be built
+func (w Workload) Validate() error {
+	_, err := w.generator()
+	return err
+}
+
+// NewWorkloadJSON returns a Requester implementing a closed model for the
+// workload: the c argument of its Run method is the number of virtual users
+// iterating one after the other.
+func NewWorkloadJSON(w Workload, timeout time.Duration) (Requester, error) {
+	g, err := w.generator()
+	if err != nil {
+		return nil, err
+	}
+	return closedRequester{Generator: g, Timeout: timeout}, nil
+}
+
+// NewRateWorkloadJSON returns a Requester implementing an open model for the
+// workload: every arrival is a new virtual user running a single iteration.
+func NewRateWorkloadJSON(w Workload, timeout time.Duration) (Requester, error) {
+	g, err := w.generator()
+	if err != nil {
+		return nil, err
+	}
+	return rateRequester{Generator: g, Timeout: timeout}, nil
+}
+
+// Duration is a time.Duration encoded as a string (e.g. "1.5s") in JSON. It
+// also accepts numbers, as nanoseconds.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value)
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", string(b))
+	}
+	return nil
+}
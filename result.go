@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+type ResultStatus string
+
+const (
+	ResultCompleted ResultStatus = "completed"
+	ResultCancelled ResultStatus = "cancelled"
+	// ResultStopped marks the ramps aborted because a stop condition was met
+	ResultStopped ResultStatus = "stopped"
+)
+
+// ResultVersion is the version of the Result format written by this build.
+// Results without a version were stored before the plan was persisted.
+const ResultVersion = 1
+
+var ErrInvalidResult = errors.New("invalid result")
+
+// Result is the document stored in the DB for every executed plan
+type Result struct {
+	Version int
+	Plan    *Plan `json:",omitempty"`
+	Status  ResultStatus
+	Reports []requester.Report
+	Search  *SearchResult `json:",omitempty"`
+	// Warmup holds the report of the warmup, excluded from the steps
+	Warmup *requester.Report `json:",omitempty"`
+	// StopReason describes the stop condition aborting the plan, if any
+	StopReason string `json:",omitempty"`
+	// Regression is the verdict of the check against the baseline, if any
+	Regression *Verdict `json:",omitempty"`
+	// Created and Finished are the times the execution started and ended
+	Created  time.Time `json:",omitempty"`
+	Finished time.Time `json:",omitempty"`
+}
+
+func (r Result) Partial() bool {
+	return r.Status != ResultCompleted
+}
+
+func encodeResult(res Result) (io.Reader, error) {
+	res.Version = ResultVersion
+	data := &bytes.Buffer{}
+	err := json.NewEncoder(data).Encode(res)
+	return data, err
+}
+
+// decodeResult parses a stored result. Results stored before the introduction
+// of the Result envelope (a bare list of reports) are also accepted.
+func decodeResult(r io.Reader) (Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		res := Result{Status: ResultCompleted}
+		err := json.Unmarshal(trimmed, &res.Reports)
+		return res, err
+	}
+
+	res := Result{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return res, err
+	}
+	if res.Reports == nil || res.Version > ResultVersion {
+		return res, ErrInvalidResult
+	}
+	return res, nil
+}
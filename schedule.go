@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule selects how the steps of a plan are generated
+type Schedule string
+
+const (
+	// ScheduleLinear ramps from min to max (excluded) adding the step size
+	ScheduleLinear Schedule = "linear"
+	// ScheduleLinearInclusive ramps from min to max (included) adding the step size
+	ScheduleLinearInclusive Schedule = "linear-inclusive"
+	// ScheduleGeometric doubles the load from min until max (included)
+	ScheduleGeometric Schedule = "geometric"
+	// ScheduleStepDown ramps down from max to min (included) removing the step size
+	ScheduleStepDown Schedule = "step-down"
+	// ScheduleSpike runs the min load, jumps to the max one and recovers to the min
+	ScheduleSpike Schedule = "spike"
+	// ScheduleCustom runs the explicit list of steps of the plan
+	ScheduleCustom Schedule = "custom"
+)
+
+var ErrEmptySchedule = errors.New("the plan schedule has no steps")
+
+// Step is a single stage of a plan
+type Step struct {
+	// Load is the concurrency or the rate, depending on the plan mode
+	Load     int
+	Duration time.Duration `json:",omitempty"`
+	Sleep    time.Duration `json:",omitempty"`
+}
+
+// schedule returns the steps to run for the plan. Steps without duration or
+// sleep inherit the ones of the plan.
+func (e Plan) schedule() ([]Step, error) {
+	loads := []int{}
+	switch e.Schedule {
+	case "", ScheduleLinear:
+		for i := e.Min; e.Steps > 0 && i < e.Max; i += e.Steps {
+			loads = append(loads, i)
+		}
+	case ScheduleLinearInclusive:
+		for i := e.Min; e.Steps > 0 && i <= e.Max; i += e.Steps {
+			loads = append(loads, i)
+		}
+	case ScheduleGeometric:
+		for i := max(e.Min, 1); i <= e.Max; i *= 2 {
+			loads = append(loads, i)
+		}
+		if len(loads) > 0 && loads[len(loads)-1] != e.Max {
+			loads = append(loads, e.Max)
+		}
+	case ScheduleStepDown:
+		for i := e.Max; e.Steps > 0 && i >= e.Min; i -= e.Steps {
+			loads = append(loads, i)
+		}
+	case ScheduleSpike:
+		loads = append(loads, e.Min, e.Max, e.Min)
+	case ScheduleCustom:
+	default:
+		return nil, fmt.Errorf("unknown schedule %q", e.Schedule)
+	}
+
+	steps := make([]Step, 0, len(loads)+len(e.Stages))
+	for _, load := range loads {
+		steps = append(steps, Step{Load: load})
+	}
+	if e.Schedule == ScheduleCustom {
+		steps = append(steps, e.Stages...)
+	}
+	if len(steps) == 0 {
+		return nil, ErrEmptySchedule
+	}
+
+	for i := range steps {
+		if steps[i].Duration <= 0 {
+			steps[i].Duration = e.Duration
+		}
+		if steps[i].Sleep <= 0 {
+			steps[i].Sleep = e.Sleep
+		}
+	}
+	return steps, nil
+}
+
+// timeout returns the time the requester must be able to run to cover the
+// longest step or the warmup
+func (e Plan) timeout(steps []Step) time.Duration {
+	timeout := e.Duration
+	for _, step := range steps {
+		if step.Duration > timeout {
+			timeout = step.Duration
+		}
+	}
+	if e.Warmup != nil && e.Warmup.Duration > timeout {
+		timeout = e.Warmup.Duration
+	}
+	return timeout
+}
+
+// ParseStages parses a list of steps, one per line, with the format
+// load[,duration[,sleep]]. Durations accept units (30s, 1m) and default to
+// seconds.
+func ParseStages(txt string) ([]Step, error) {
+	steps := []Step{}
+	for i, line := range strings.Split(txt, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: too many fields", i+1)
+		}
+		load, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: parsing the load: %s", i+1, err.Error())
+		}
+		step := Step{Load: load}
+		if len(fields) > 1 {
+			if step.Duration, err = parseSeconds(fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: parsing the duration: %s", i+1, err.Error())
+			}
+		}
+		if len(fields) > 2 {
+			if step.Sleep, err = parseSeconds(fields[2]); err != nil {
+				return nil, fmt.Errorf("line %d: parsing the sleep: %s", i+1, err.Error())
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// FormatStages is the inverse of ParseStages
+func FormatStages(steps []Step) string {
+	lines := make([]string, len(steps))
+	for i, s := range steps {
+		lines[i] = fmt.Sprintf("%d,%s,%s", s.Load, s.Duration, s.Sleep)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func parseSeconds(v string) (time.Duration, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		return time.Duration(s) * time.Second, nil
+	}
+	return time.ParseDuration(v)
+}
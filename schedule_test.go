@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kpacha/load-test/db"
+	"github.com/kpacha/load-test/requester"
+)
+
+func TestPlan_schedule(t *testing.T) {
+	d, s := 10*time.Second, time.Second
+	for _, tc := range []struct {
+		plan  Plan
+		loads []int
+	}{
+		{plan: Plan{Min: 1, Max: 10, Steps: 3}, loads: []int{1, 4, 7}},
+		{plan: Plan{Min: 1, Max: 10, Steps: 3, Schedule: ScheduleLinearInclusive}, loads: []int{1, 4, 7, 10}},
+		{plan: Plan{Min: 0, Max: 20, Schedule: ScheduleGeometric}, loads: []int{1, 2, 4, 8, 16, 20}},
+		{plan: Plan{Min: 2, Max: 16, Schedule: ScheduleGeometric}, loads: []int{2, 4, 8, 16}},
+		{plan: Plan{Min: 5, Max: 20, Steps: 5, Schedule: ScheduleStepDown}, loads: []int{20, 15, 10, 5}},
+		{plan: Plan{Min: 5, Max: 50, Schedule: ScheduleSpike}, loads: []int{5, 50, 5}},
+	} {
+		tc.plan.Duration, tc.plan.Sleep = d, s
+		steps, err := tc.plan.schedule()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.plan.Schedule, err.Error())
+			continue
+		}
+		loads := []int{}
+		for _, step := range steps {
+			loads = append(loads, step.Load)
+			if step.Duration != d || step.Sleep != s {
+				t.Errorf("%s: unexpected step: %+v", tc.plan.Schedule, step)
+			}
+		}
+		if !reflect.DeepEqual(loads, tc.loads) {
+			t.Errorf("%s: unexpected loads: %v", tc.plan.Schedule, loads)
+		}
+	}
+
+	custom := Plan{
+		Duration: d,
+		Sleep:    s,
+		Schedule: ScheduleCustom,
+		Stages:   []Step{{Load: 5}, {Load: 50, Duration: time.Minute}, {Load: 5, Sleep: 5 * time.Second}},
+	}
+	steps, err := custom.schedule()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []Step{{Load: 5, Duration: d, Sleep: s}, {Load: 50, Duration: time.Minute, Sleep: s}, {Load: 5, Duration: d, Sleep: 5 * time.Second}}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("unexpected custom steps: %+v", steps)
+	}
+
+	if _, err := (Plan{Schedule: ScheduleCustom}).schedule(); err != ErrEmptySchedule {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := (Plan{Schedule: "unknown"}).schedule(); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestParseStages(t *testing.T) {
+	steps, err := ParseStages("10\n\n# plateau\n50, 60, 5\n10,1m30s\n")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []Step{{Load: 10}, {Load: 50, Duration: time.Minute, Sleep: 5 * time.Second}, {Load: 10, Duration: 90 * time.Second}}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("unexpected steps: %+v", steps)
+	}
+
+	parsed, err := ParseStages(FormatStages(expected))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("unexpected steps after formatting: %+v", parsed)
+	}
+
+	for _, txt := range []string{"a", "1,2,3,4", "1,x", "1,2,y"} {
+		if _, err := ParseStages(txt); err == nil {
+			t.Errorf("error expected parsing %q", txt)
+		}
+	}
+}
+
+func Test_executor_Run_customSchedule(t *testing.T) {
+	loads := []int{}
+	exec := executor{
+		DB: db.NewInMemory(),
+		RequesterFactory: func(req *http.Request, timeout time.Duration) requester.Requester {
+			if timeout != time.Second {
+				t.Errorf("unexpected timeout: %s", timeout)
+			}
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				loads = append(loads, c)
+				return bytes.NewBufferString(`{"NumRes":1}`)
+			})
+		},
+	}
+	p := Plan{
+		Name:     "some-name",
+		Duration: time.Millisecond,
+		Request:  requester.Request{Method: "GET", URL: "/"},
+		Schedule: ScheduleCustom,
+		Stages:   []Step{{Load: 3}, {Load: 30, Duration: time.Second}, {Load: 3}},
+	}
+
+	reports, err := exec.Run(context.Background(), p)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(reports) != 3 {
+		t.Errorf("unexpected number of reports: %d", len(reports))
+	}
+	if !reflect.DeepEqual(loads, []int{3, 30, 3}) {
+		t.Errorf("unexpected loads: %v", loads)
+	}
+}
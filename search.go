@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+// SLO defines the service level a step must meet to consider its load sustainable
+type SLO struct {
+	// Percentile of the latency to check (e.g. 99 or 99.9)
+	Percentile float64
+	// Latency is the max value allowed for the percentile
+	Latency time.Duration
+	// ErrorRatio is the max share of failed requests allowed (e.g. 0.01)
+	ErrorRatio float64
+}
+
+func (s SLO) String() string {
+	return fmt.Sprintf("p%g < %s, errors < %g%%", s.Percentile, s.Latency, s.ErrorRatio*100)
+}
+
+// Check returns an empty string if the report meets the SLO or the description
+// of the violation
+func (s SLO) Check(r requester.Report) string {
+	if ratio := r.ErrorRatio(); ratio > s.ErrorRatio {
+		return fmt.Sprintf("error ratio %.4f above %g", ratio, s.ErrorRatio)
+	}
+	if s.Latency <= 0 {
+		return ""
+	}
+	if r.NumRes == 0 || !r.HasLatencies() {
+		return "no successful responses"
+	}
+	if l := time.Duration(r.Percentile(s.Percentile) * float64(time.Second)); l > s.Latency {
+		return fmt.Sprintf("p%g latency %s above %s", s.Percentile, l, s.Latency)
+	}
+	return ""
+}
+
+// SearchResult describes the outcome of a capacity search
+type SearchResult struct {
+	// Sustainable is the highest load meeting the SLO or 0 if even the min load
+	// violated it
+	Sustainable int
+	// Probes holds the verdict of every step run, in the same order as the
+	// reports of the result
+	Probes []Probe
+}
+
+type Probe struct {
+	Load      int
+	Phase     string
+	Violation string `json:",omitempty"`
+}
+
+func (p Probe) Passed() bool {
+	return p.Violation == ""
+}
+
+const (
+	searchPhaseRamp   = "ramp"
+	searchPhaseBisect = "bisect"
+)
+
+// validateSearch checks the plan bounds leave some load to probe
+func (e Plan) validateSearch() error {
+	if e.Max < 1 {
+		return errors.New("the capacity search requires a max load")
+	}
+	if e.Min > e.Max {
+		return fmt.Errorf("the min load %d is above the max load %d", e.Min, e.Max)
+	}
+	return nil
+}
+
+// searchCapacity looks for the highest load meeting the SLO of the plan. First,
+// it doubles the load from the plan min until the SLO is violated or the plan
+// max is reached. Then it bisects the interval between the last sustainable
+// load and the first violating one until it is narrower than the plan steps.
+func (e *executor) searchCapacity(ctx context.Context, plan Plan, requestr requester.Requester, res *Result) error {
+	search := &SearchResult{Probes: []Probe{}}
+	res.Search = search
+
+	probe := func(load int, phase string) (bool, error) {
+		report, err := e.runStep(ctx, plan, requestr, Step{Load: load, Duration: plan.Duration, Sleep: plan.Sleep}, res)
+		if err != nil {
+			return false, err
+		}
+		p := Probe{Load: load, Phase: phase, Violation: plan.SLO.Check(report)}
+		search.Probes = append(search.Probes, p)
+		log.Printf("probe with load %d passed: %v %s", load, p.Passed(), p.Violation)
+		return p.Passed(), nil
+	}
+
+	precision := plan.Steps
+	if precision < 1 {
+		precision = 1
+	}
+
+	lo, hi := 0, 0
+	for load := max(plan.Min, 1); ; load *= 2 {
+		if load > plan.Max {
+			load = plan.Max
+		}
+		ok, err := probe(load, searchPhaseRamp)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			hi = load
+			break
+		}
+		lo = load
+		if load >= plan.Max {
+			break
+		}
+	}
+
+	for lo > 0 && hi > 0 && hi-lo > precision {
+		mid := lo + (hi-lo)/2
+		ok, err := probe(mid, searchPhaseBisect)
+		if err != nil {
+			return err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	search.Sustainable = lo
+	log.Printf("capacity search completed. sustainable load: %d", lo)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kpacha/load-test/db"
+	"github.com/kpacha/load-test/requester"
+	hey "github.com/rakyll/hey/requester"
+)
+
+func Test_executor_Run_searchCapacity(t *testing.T) {
+	store := db.NewInMemory()
+	exec := executor{
+		DB: store,
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				if c > 37 {
+					return bytes.NewBufferString(`{"NumRes":2,"Lats":[0.1,0.5]}`)
+				}
+				return bytes.NewBufferString(`{"NumRes":2,"Lats":[0.1,0.2]}`)
+			})
+		},
+	}
+	p := Plan{
+		Name:     "some-name",
+		Min:      1,
+		Max:      100,
+		Steps:    1,
+		Duration: 1,
+		Request:  requester.Request{Method: "GET", URL: "/"},
+		SLO:      &SLO{Percentile: 99, Latency: 250 * time.Millisecond, ErrorRatio: 0.01},
+	}
+
+	if _, err := exec.Run(context.Background(), p); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	r, err := store.Get(p.Name)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res, err := decodeResult(r)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if res.Search == nil {
+		t.Error("the search result was not stored")
+		return
+	}
+	if res.Search.Sustainable != 37 {
+		t.Errorf("unexpected sustainable load: %d", res.Search.Sustainable)
+	}
+
+	expected := []Probe{
+		{Load: 1, Phase: searchPhaseRamp},
+		{Load: 2, Phase: searchPhaseRamp},
+		{Load: 4, Phase: searchPhaseRamp},
+		{Load: 8, Phase: searchPhaseRamp},
+		{Load: 16, Phase: searchPhaseRamp},
+		{Load: 32, Phase: searchPhaseRamp},
+		{Load: 64, Phase: searchPhaseRamp, Violation: "p99 latency 500ms above 250ms"},
+		{Load: 48, Phase: searchPhaseBisect, Violation: "p99 latency 500ms above 250ms"},
+		{Load: 40, Phase: searchPhaseBisect, Violation: "p99 latency 500ms above 250ms"},
+		{Load: 36, Phase: searchPhaseBisect},
+		{Load: 38, Phase: searchPhaseBisect, Violation: "p99 latency 500ms above 250ms"},
+		{Load: 37, Phase: searchPhaseBisect},
+	}
+	if len(res.Search.Probes) != len(expected) || len(res.Reports) != len(expected) {
+		t.Errorf("unexpected number of probes: %d (%d reports)", len(res.Search.Probes), len(res.Reports))
+		return
+	}
+	for i, probe := range res.Search.Probes {
+		if probe != expected[i] {
+			t.Errorf("unexpected probe #%d: %+v", i, probe)
+		}
+		if res.Reports[i].C != probe.Load {
+			t.Errorf("unexpected report #%d: C = %d", i, res.Reports[i].C)
+		}
+	}
+}
+
+func TestSLO_Check(t *testing.T) {
+	slo := SLO{Percentile: 50, Latency: 100 * time.Millisecond, ErrorRatio: 0.1}
+	for _, tc := range []struct {
+		report    hey.Report
+		violation string
+	}{
+		{
+			report: hey.Report{NumRes: 3, Lats: []float64{0.01, 0.02, 0.2}},
+		},
+		{
+			report:    hey.Report{NumRes: 3, Lats: []float64{0.01, 0.2, 0.2}},
+			violation: "p50 latency 200ms above 100ms",
+		},
+		{
+			report:    hey.Report{NumRes: 4, Lats: []float64{0.01, 0.02, 0.02}, ErrorDist: map[string]int{"timeout": 1}},
+			violation: "error ratio 0.2500 above 0.1",
+		},
+		{
+			report:    hey.Report{NumRes: 2, Lats: []float64{0.01, 0.02}, StatusCodeDist: map[int]int{200: 1, 503: 1}},
+			violation: "error ratio 0.5000 above 0.1",
+		},
+		{
+			violation: "no successful responses",
+		},
+	} {
+		if v := slo.Check(requester.Report{Report: tc.report}); v != tc.violation {
+			t.Errorf("unexpected violation. have %q want %q", v, tc.violation)
+		}
+	}
+}
@@ -3,12 +3,15 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,12 +26,13 @@ type Server interface {
 	Run(ctx context.Context, addr string) error
 }
 
-func NewServer(engine *gin.Engine, db db.DB, executor Executor, isDevel bool) (*SimpleServer, error) {
+func NewServer(engine *gin.Engine, db db.DB, jobs JobRegistry, events *Broker, isDevel bool) (*SimpleServer, error) {
 	s := &SimpleServer{
-		Engine:   engine,
-		DB:       db,
-		Executor: executor,
-		IsDevel:  isDevel,
+		Engine:  engine,
+		DB:      db,
+		Jobs:    jobs,
+		Events:  events,
+		IsDevel: isDevel,
 	}
 	tmpl, err := s.getHTMLTemplate()
 	if err != nil {
@@ -37,10 +41,27 @@ func NewServer(engine *gin.Engine, db db.DB, executor Executor, isDevel bool) (*
 	s.Engine.SetHTMLTemplate(tmpl)
 
 	s.Engine.POST("/test", s.testHandler)
+	s.Engine.GET("/jobs", s.jobsHandler)
+	s.Engine.GET("/jobs/:id", s.jobHandler)
+	s.Engine.DELETE("/jobs/:id", s.removeJobHandler)
+	s.Engine.POST("/jobs/:id/cancel", s.cancelJobHandler)
+	s.Engine.GET("/jobs/:id/events", s.jobEventsHandler)
 	s.Engine.GET("/flush-cache", s.flushAllCacheHandler)
 	s.Engine.GET("/flush-cache/:id", s.flushCacheHandler)
+	s.Engine.GET("/tests", s.testsHandler)
 	s.Engine.GET("/browse/:id", s.browseHandler)
+	s.Engine.GET("/compare", s.compareHandler)
+	s.Engine.POST("/browse/:id/rerun", s.rerunHandler)
+	s.Engine.POST("/browse/:id/baseline", s.baselineHandler)
+	s.Engine.DELETE("/browse/:id", s.deleteHandler)
+	s.Engine.POST("/browse/:id/rename", s.renameHandler)
+	s.Engine.GET("/browse/:id/meta", s.metaHandler)
+	s.Engine.POST("/browse/:id/meta", s.setMetaHandler)
+	s.Engine.GET("/baselines", s.baselinesHandler)
+	s.Engine.DELETE("/baselines/:scope", s.removeBaselineHandler)
 	s.Engine.GET("/download/:id", s.downloadHandler)
+	s.Engine.GET("/download/:id/samples", s.samplesHandler)
+	s.Engine.GET("/download/:id/percentiles", s.percentilesHandler)
 	s.Engine.GET("/", s.homeHandler)
 
 	return s, nil
@@ -49,27 +70,44 @@ func NewServer(engine *gin.Engine, db db.DB, executor Executor, isDevel bool) (*
 var templateFilePattern = "templates/*.html"
 
 type SimpleServer struct {
-	Engine   *gin.Engine
-	DB       db.DB
-	Executor Executor
-	IsDevel  bool
+	Engine  *gin.Engine
+	DB      db.DB
+	Jobs    JobRegistry
+	Events  *Broker
+	IsDevel bool
 }
 
 func (s *SimpleServer) getHTMLTemplate() (*template.Template, error) {
 	funcMap := template.FuncMap{
 		"formatLatency": formatLatency,
+		"formatTime":    formatTime,
+		"formatDate":    formatDate,
+		"stepEvent":     newStepEndEvent,
+		"formatHeaders": formatHeaders,
+		"seconds":       func(d time.Duration) int { return int(d / time.Second) },
+		"millis":        func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) },
+		"percent":       func(f float64) float64 { return f * 100 },
+		"timeline":      timeline,
+		"allLatencies":  mergeLatencies,
+		"allCorrected":  mergeCorrected,
+		"list":          func(v ...string) []string { return v },
+		"pages":         pages,
+		"formatStages":  FormatStages,
+		"toJSON":        toJSON,
 	}
 	if s.IsDevel {
-		tmpl, err := template.ParseGlob(templateFilePattern)
-		return tmpl.Funcs(funcMap), err
+		return template.New("main").Funcs(funcMap).ParseGlob(templateFilePattern)
 	}
 
 	buff := new(bytes.Buffer)
 
 	for _, name := range []string{
 		"templates/browse.html",
+		"templates/compare.html",
 		"templates/index.html",
+		"templates/job.html",
 		"templates/partials.html",
+		"templates/tests.html",
 	} {
 		f, err := fs.Open(name)
 		if err != nil {
@@ -94,6 +132,7 @@ func (s *SimpleServer) Run(ctx context.Context, addr string) error {
 			log.Printf("listen: %s\n", err)
 		}
 	}()
+	go s.syncIndexEvery(ctx, indexSyncInterval)
 
 	<-ctx.Done()
 	log.Println("Shutdown Server ...")
@@ -108,15 +147,272 @@ func (s *SimpleServer) Run(ctx context.Context, addr string) error {
 	return nil
 }
 
+// sidebarSize is the number of tests listed in the sidebar
+const sidebarSize = 25
+
+// keys lists the stored tests, newest first
+func (s *SimpleServer) keys() ([]string, error) {
+	index, err := readIndex(s.DB)
+	if err != nil {
+		return []string{}, err
+	}
+	return indexIDs(index), nil
+}
+
+// listing adds the ids of the stored tests and the latest ones, listed in
+// the sidebar, to the response
+func (s *SimpleServer) listing(res gin.H) error {
+	index, err := readIndex(s.DB)
+	if err != nil {
+		return err
+	}
+	res["keys"] = indexIDs(index)
+	res["tests"] = IndexQuery{Desc: true, PerPage: sidebarSize}.apply(index)
+	return nil
+}
+
 func (s *SimpleServer) homeHandler(c *gin.Context) {
-	keys, err := s.DB.Keys()
+	res := gin.H{
+		"jobs": s.Jobs.List(),
+	}
+	if err := s.listing(res); err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	if from := c.Query("from"); from != "" {
+		plan, err := s.storedPlan(from)
+		if err != nil {
+			s.abortWithStoreError(c, err)
+			return
+		}
+		res["plan"] = plan
+	}
+
+	c.HTML(200, "index", res)
+}
+
+func (s *SimpleServer) rerunHandler(c *gin.Context) {
+	plan, err := s.storedPlan(c.Param("id"))
+	if err != nil {
+		s.abortWithStoreError(c, err)
+		return
+	}
+	log.Println("re-running the test", plan.Name)
+
+	job := s.Jobs.Submit(*plan)
+
+	c.Header("Location", "/jobs/"+job.ID)
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.Redirect(http.StatusSeeOther, "/jobs/"+job.ID)
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+var errPlanNotStored = errors.New("the plan was not stored with the results")
+
+// baselineHandler marks the stored test as the baseline of its name or, if
+// the tag param is set, of the tag. The tolerances are optional params.
+func (s *SimpleServer) baselineHandler(c *gin.Context) {
+	id := c.Param("id")
+	if isInternalKey(id) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	r, err := s.DB.Get(id)
+	if err != nil {
+		s.abortWithStoreError(c, err)
+		return
+	}
+	res, err := decodeResult(r)
 	if err != nil {
 		c.AbortWithError(500, err)
 		return
 	}
-	c.HTML(200, "index", gin.H{
-		"keys": keys,
+
+	scope := nameScope(id)
+	if res.Plan != nil && res.Plan.Name != "" {
+		scope = nameScope(res.Plan.Name)
+	}
+	if tag := strings.TrimSpace(c.PostForm("tag")); tag != "" {
+		scope = tagScope(tag)
+	}
+	b := newBaseline(scope, id, res, getTolerances(c))
+	if err := setBaseline(s.DB, b); err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+	log.Printf("%s marked as the baseline of %s", id, scope)
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.Redirect(http.StatusSeeOther, "/browse/"+id)
+		return
+	}
+	c.JSON(http.StatusCreated, b)
+}
+
+func (s *SimpleServer) baselinesHandler(c *gin.Context) {
+	baselinesMutex.Lock()
+	baselines, err := loadBaselines(s.DB)
+	baselinesMutex.Unlock()
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+	c.JSON(200, baselines)
+}
+
+func (s *SimpleServer) removeBaselineHandler(c *gin.Context) {
+	switch err := removeBaseline(s.DB, c.Param("scope")); err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case db.ErrNotFound:
+		c.AbortWithStatus(http.StatusNotFound)
+	default:
+		c.AbortWithError(500, err)
+	}
+}
+
+func (s *SimpleServer) deleteHandler(c *gin.Context) {
+	id := c.Param("id")
+	if isInternalKey(id) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if err := s.DB.Delete(id); err != nil {
+		s.abortWithStoreError(c, err)
+		return
+	}
+	invalidateCache(id)
+	if err := updateIndex(s.DB, func(index map[string]TestInfo) { delete(index, id) }); err != nil {
+		log.Printf("updating the index: %s", err.Error())
+	}
+	log.Println("test deleted:", id)
+	c.Status(http.StatusNoContent)
+}
+
+// renameHandler moves the stored test to the key of the name param
+func (s *SimpleServer) renameHandler(c *gin.Context) {
+	id := c.Param("id")
+	if isInternalKey(id) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	name := strings.TrimSpace(c.PostForm("name"))
+	if err := validateName(name); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	if err := s.DB.Rename(id, name); err != nil {
+		s.abortWithStoreError(c, err)
+		return
+	}
+	invalidateCache(id, name)
+	err := updateIndex(s.DB, func(index map[string]TestInfo) {
+		if info, ok := index[id]; ok {
+			delete(index, id)
+			info.ID = name
+			index[name] = info
+		}
+	})
+	if err != nil {
+		log.Printf("updating the index: %s", err.Error())
+	}
+	log.Printf("test %s renamed to %s", id, name)
+
+	c.Header("Location", "/browse/"+name)
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.Redirect(http.StatusSeeOther, "/browse/"+name)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *SimpleServer) metaHandler(c *gin.Context) {
+	id := c.Param("id")
+	if isInternalKey(id) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	m, err := s.DB.Meta(id)
+	if err != nil {
+		s.abortWithStoreError(c, err)
+		return
+	}
+	c.JSON(200, m)
+}
+
+// setMetaHandler replaces the tags (comma separated) and the notes of the
+// stored test
+func (s *SimpleServer) setMetaHandler(c *gin.Context) {
+	id := c.Param("id")
+	if isInternalKey(id) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	m := db.Metadata{Tags: getTags(c), Notes: strings.TrimSpace(c.PostForm("notes"))}
+	if err := s.DB.SetMeta(id, m); err != nil {
+		s.abortWithStoreError(c, err)
+		return
+	}
+	err := updateIndex(s.DB, func(index map[string]TestInfo) {
+		if info, ok := index[id]; ok {
+			info.Tags, info.Notes = m.Tags, m.Notes
+			index[id] = info
+		}
 	})
+	if err != nil {
+		log.Printf("updating the index: %s", err.Error())
+	}
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.Redirect(http.StatusSeeOther, "/browse/"+id)
+		return
+	}
+	c.JSON(200, m)
+}
+
+// validateName checks the name can be used as the key of a stored test
+func validateName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("the name can not be empty")
+	case isInternalKey(name):
+		return fmt.Errorf("the names starting with %q are reserved", internalKeyPrefix)
+	case strings.Contains(name, "/") || strings.Contains(name, ".."):
+		return errors.New("the name can not contain '/' or '..'")
+	}
+	return nil
+}
+
+// storedPlan returns the plan stored along the results of the given test
+func (s *SimpleServer) storedPlan(id string) (*Plan, error) {
+	r, err := s.DB.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	res, err := decodeResult(r)
+	if err != nil {
+		return nil, err
+	}
+	if res.Plan == nil {
+		return nil, errPlanNotStored
+	}
+	plan := *res.Plan
+	plan.ID = ""
+	return &plan, nil
+}
+
+func (s *SimpleServer) abortWithStoreError(c *gin.Context, err error) {
+	switch err {
+	case db.ErrNotFound:
+		c.AbortWithStatus(http.StatusNotFound)
+	case errPlanNotStored, db.ErrAlreadyExists:
+		c.AbortWithError(http.StatusConflict, err)
+	default:
+		c.AbortWithError(500, err)
+	}
 }
 
 var (
@@ -124,18 +420,46 @@ var (
 	cache = map[string]gin.H{}
 )
 
+// flushAllCacheHandler empties the cache and reconciles the index with the
+// stored tests
 func (s *SimpleServer) flushAllCacheHandler(c *gin.Context) {
 	mutex.Lock()
 	defer mutex.Unlock()
 	cache = map[string]gin.H{}
+	if err := syncIndex(s.DB, true); err != nil {
+		log.Printf("syncing the index: %s", err.Error())
+	}
 	c.Redirect(301, "/")
 }
 
+// syncIndexEvery reconciles the index with the stored tests on start and then
+// every interval, until the ctx is done
+func (s *SimpleServer) syncIndexEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := syncIndex(s.DB, false); err != nil {
+			log.Printf("syncing the index: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (s *SimpleServer) flushCacheHandler(c *gin.Context) {
+	invalidateCache(c.Param("id"))
+	c.Redirect(301, "/")
+}
+
+func invalidateCache(ids ...string) {
 	mutex.Lock()
 	defer mutex.Unlock()
-	delete(cache, c.Param("id"))
-	c.Redirect(301, "/")
+	for _, id := range ids {
+		delete(cache, id)
+	}
 }
 
 func (s *SimpleServer) browseHandler(c *gin.Context) {
@@ -144,8 +468,9 @@ func (s *SimpleServer) browseHandler(c *gin.Context) {
 	defer mutex.Unlock()
 
 	if res, ok := cache[id]; ok {
-		keys, _ := s.DB.Keys()
-		res["keys"] = keys
+		s.listing(res)
+		res["percentiles"] = parsePercentiles(c.QueryArray("p"))
+		res["meta"], _ = s.DB.Meta(id)
 		c.HTML(200, "browse", res)
 		return
 	}
@@ -161,23 +486,29 @@ func (s *SimpleServer) browseHandler(c *gin.Context) {
 		return
 	}
 
-	reports := []requester.Report{}
-	if err := json.NewDecoder(r).Decode(&reports); err != nil {
+	res, err := decodeResult(r)
+	if err != nil {
 		c.AbortWithError(500, err)
 		return
 	}
 	result := gin.H{
-		"reports": reports,
+		"reports": res.Reports,
+		"status":  res.Status,
+		"plan":    res.Plan,
+		"search":  res.Search,
+		"stop":    res.StopReason,
+		"warmup":  res.Warmup,
+		"verdict": res.Regression,
 		"id":      id,
 	}
 	cache[id] = result
 
-	keys, err := s.DB.Keys()
-	if err != nil {
+	if err := s.listing(result); err != nil {
 		c.AbortWithError(500, err)
 		return
 	}
-	result["keys"] = keys
+	result["percentiles"] = parsePercentiles(c.QueryArray("p"))
+	result["meta"], _ = s.DB.Meta(id)
 
 	c.HTML(200, "browse", result)
 }
@@ -188,7 +519,7 @@ func (s *SimpleServer) downloadHandler(c *gin.Context) {
 	defer mutex.Unlock()
 
 	if res, ok := cache[id]; ok {
-		keys, _ := s.DB.Keys()
+		keys, _ := s.keys()
 		res["keys"] = keys
 		c.JSON(200, res)
 		return
@@ -205,18 +536,24 @@ func (s *SimpleServer) downloadHandler(c *gin.Context) {
 		return
 	}
 
-	reports := []requester.Report{}
-	if err := json.NewDecoder(r).Decode(&reports); err != nil {
+	res, err := decodeResult(r)
+	if err != nil {
 		c.AbortWithError(500, err)
 		return
 	}
 	result := gin.H{
-		"reports": reports,
+		"reports": res.Reports,
+		"status":  res.Status,
+		"plan":    res.Plan,
+		"search":  res.Search,
+		"stop":    res.StopReason,
+		"warmup":  res.Warmup,
+		"verdict": res.Regression,
 		"id":      id,
 	}
 	cache[id] = result
 
-	keys, err := s.DB.Keys()
+	keys, err := s.keys()
 	if err != nil {
 		c.AbortWithError(500, err)
 		return
@@ -226,6 +563,159 @@ func (s *SimpleServer) downloadHandler(c *gin.Context) {
 	c.JSON(200, result)
 }
 
+// testsHandler searches the stored tests by name, target, tags, status,
+// verdict and creation date, returning the page sorted by the sort and order
+// params
+func (s *SimpleServer) testsHandler(c *gin.Context) {
+	q, err := getIndexQuery(c)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	page, err := queryIndex(s.DB, q)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		c.JSON(200, page)
+		return
+	}
+	res := gin.H{"page": page, "query": q}
+	if err := s.listing(res); err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+	c.HTML(200, "tests", res)
+}
+
+// compareHandler aligns the results of the tests listed in the id param
+// (e.g. ?id=baseline&id=candidate) by their load. The first one is the baseline.
+func (s *SimpleServer) compareHandler(c *gin.Context) {
+	ids := []string{}
+	for _, v := range c.QueryArray("id") {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) < 2 {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("at least two results are required"))
+		return
+	}
+
+	results := make([]Result, len(ids))
+	for i, id := range ids {
+		r, err := s.DB.Get(id)
+		switch err {
+		case db.ErrNotFound:
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		case nil:
+		default:
+			c.AbortWithError(500, err)
+			return
+		}
+		if results[i], err = decodeResult(r); err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+	}
+
+	cmp, err := compareResults(ids, results)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		c.JSON(200, cmp)
+		return
+	}
+	plans := make([]*Plan, len(results))
+	for i, res := range results {
+		plans[i] = res.Plan
+	}
+	res := gin.H{"comparison": cmp, "plans": plans, "threshold": significantChange}
+	if err := s.listing(res); err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+	c.HTML(200, "compare", res)
+}
+
+// percentilesHandler returns the latencies of the percentiles requested with
+// the p param (e.g. ?p=99.9&p=99.99) for every step and the whole plan
+func (s *SimpleServer) percentilesHandler(c *gin.Context) {
+	r, err := s.DB.Get(c.Param("id"))
+	switch err {
+	case db.ErrNotFound:
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	case nil:
+	default:
+		c.AbortWithError(500, err)
+		return
+	}
+
+	res, err := decodeResult(r)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	c.JSON(200, newPercentileReport(res.Reports, parsePercentiles(c.QueryArray("p"))))
+}
+
+// samplesHandler exports the raw samples of every step as CSV
+func (s *SimpleServer) samplesHandler(c *gin.Context) {
+	id := c.Param("id")
+	r, err := s.DB.Get(id)
+	switch err {
+	case db.ErrNotFound:
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	case nil:
+	default:
+		c.AbortWithError(500, err)
+		return
+	}
+
+	res, err := decodeResult(r)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+"-samples.csv"))
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"step", "c", "rate", "name", "start", "offset", "latency", "conn", "dns", "req", "delay", "res", "status", "bytes", "error"})
+	for i, report := range res.Reports {
+		for _, smp := range report.Samples {
+			w.Write([]string{
+				strconv.Itoa(i),
+				strconv.Itoa(report.C),
+				strconv.Itoa(report.Rate),
+				smp.Name,
+				report.Start.Add(time.Duration(smp.Offset * float64(time.Second))).Format(time.RFC3339Nano),
+				formatFloat(smp.Offset),
+				formatFloat(smp.Latency),
+				formatFloat(smp.Conn),
+				formatFloat(smp.DNS),
+				formatFloat(smp.Req),
+				formatFloat(smp.Delay),
+				formatFloat(smp.Res),
+				strconv.Itoa(smp.Status),
+				strconv.FormatInt(smp.Bytes, 10),
+				smp.Error,
+			})
+		}
+	}
+	w.Flush()
+}
+
 func (s *SimpleServer) testHandler(c *gin.Context) {
 	req, err := getRequest(c)
 	if err != nil {
@@ -233,43 +723,294 @@ func (s *SimpleServer) testHandler(c *gin.Context) {
 		c.AbortWithError(500, err)
 		return
 	}
+	mix, err := getMix(c)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	scenario, err := getScenario(c)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	feeder, err := getFeeder(c)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	if len(mix) > 0 && len(scenario) > 0 {
+		c.AbortWithError(http.StatusBadRequest, errors.New("a plan can not have a request mix and a scenario"))
+		return
+	}
+	stages, err := ParseStages(c.PostForm("stages"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("parsing the stages: %s", err.Error()))
+		return
+	}
 	name := c.PostForm("name")
-	log.Println("starting the test", name)
+	if name != "" {
+		if err := validateName(name); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+	}
+	mode, err := getMode(c)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	engine, err := getEngine(c)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
 
-	if _, err := s.Executor.Run(c, Plan{
+	plan := Plan{
 		Name:     name,
+		Mode:     mode,
+		Engine:   engine,
 		Min:      getInt(c, "min"),
 		Max:      getInt(c, "max"),
 		Steps:    getInt(c, "steps"),
 		Duration: time.Duration(getInt(c, "duration")) * time.Second,
 		Sleep:    time.Duration(getInt(c, "sleep")) * time.Second,
 		Request:  req,
-	}); err != nil {
-		fmt.Println(err.Error())
-		c.AbortWithError(500, err)
+		Priority: getIntOrDefault(c, "priority", 0),
+		SLO:      getSLO(c),
+		Schedule: Schedule(c.PostForm("schedule")),
+		Warmup:   getWarmup(c),
+		Feeder:   feeder,
+		Stop:     getStopConditions(c),
+		Interval: time.Duration(getIntOrDefault(c, "interval", 0)) * time.Millisecond,
+	}
+	plan.ExpectedInterval = time.Duration(getFloat(c, "expected_interval", 0) * float64(time.Millisecond))
+	plan.Tags = getTags(c)
+	if plan.Schedule == ScheduleCustom {
+		plan.Stages = stages
+	}
+	if len(mix) > 0 {
+		plan.Mix = mix
+		plan.Request = mix[0]
+	}
+	if len(scenario) > 0 {
+		plan.Scenario = scenario
+		plan.Request = scenario[0].Request
+	}
+	if plan.SLO == nil {
+		if _, err := plan.schedule(); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+	} else if err := plan.validateSearch(); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
-	c.Redirect(301, "/")
+
+	log.Println("submitting the test", name)
+	job := s.Jobs.Submit(plan)
+
+	c.Header("Location", "/jobs/"+job.ID)
+	s.renderJob(c, http.StatusAccepted, job)
 }
 
-func getRequest(c *gin.Context) (*http.Request, error) {
-	target := c.PostForm("url")
-	method := c.PostForm("req_method")
-	if method == "" {
-		method = "GET"
+func (s *SimpleServer) jobsHandler(c *gin.Context) {
+	c.JSON(200, s.Jobs.List())
+}
+
+func (s *SimpleServer) jobHandler(c *gin.Context) {
+	job, err := s.Jobs.Get(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	s.renderJob(c, 200, job)
+}
+
+func (s *SimpleServer) removeJobHandler(c *gin.Context) {
+	switch err := s.Jobs.Remove(c.Param("id")); err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case ErrJobNotFound:
+		c.AbortWithStatus(http.StatusNotFound)
+	case ErrJobNotQueued:
+		c.AbortWithError(http.StatusConflict, err)
+	default:
+		c.AbortWithError(500, err)
+	}
+}
+
+func (s *SimpleServer) cancelJobHandler(c *gin.Context) {
+	id := c.Param("id")
+	switch err := s.Jobs.Cancel(id); err {
+	case nil:
+	case ErrJobNotFound:
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	case ErrJobFinished:
+		c.AbortWithError(http.StatusConflict, err)
+		return
+	default:
+		c.AbortWithError(500, err)
+		return
+	}
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.Redirect(http.StatusSeeOther, "/jobs/"+id)
+		return
 	}
-	body := ioutil.NopCloser(bytes.NewBufferString(c.PostForm("body")))
-	req, err := http.NewRequest(method, target, body)
+
+	job, err := s.Jobs.Get(id)
 	if err != nil {
-		fmt.Println("building request:", err.Error())
-		return nil, err
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// jobEventsHandler streams the progress of the job as server-sent events
+func (s *SimpleServer) jobEventsHandler(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := s.Jobs.Get(id); err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	history, events, unsubscribe := s.Events.Subscribe(id)
+	defer unsubscribe()
+
+	for _, e := range history {
+		c.SSEvent(string(e.Type), e)
 	}
+	c.Writer.Flush()
 
-	req.Header = parseHeaders(c.PostForm("headers"))
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(string(e.Type), e)
+			return true
+		case <-ticker.C:
+			// jobs removed from the queue never publish any event
+			if job, err := s.Jobs.Get(id); err != nil || job.Status.Done() {
+				return false
+			}
+			c.SSEvent("ping", time.Now())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (s *SimpleServer) renderJob(c *gin.Context, status int, job Job) {
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		c.JSON(status, job)
+		return
+	}
+
+	res := gin.H{"job": job}
+	if err := s.listing(res); err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+	c.HTML(status, "job", res)
+}
 
+func getRequest(c *gin.Context) (requester.Request, error) {
+	req := requester.Request{
+		Method: c.PostForm("req_method"),
+		URL:    c.PostForm("url"),
+		Header: parseHeaders(c.PostForm("headers")),
+		Body:   c.PostForm("body"),
+	}
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+	if req.IsTemplate() {
+		if err := (requester.Workload{Mix: []requester.Request{req}}).Validate(); err != nil {
+			fmt.Println("building request:", err.Error())
+			return req, err
+		}
+		return req, nil
+	}
+	if _, err := req.HTTPRequest(); err != nil {
+		fmt.Println("building request:", err.Error())
+		return req, err
+	}
 	return req, nil
 }
 
+// getMix returns the request mix of the plan, if any
+func getMix(c *gin.Context) ([]requester.Request, error) {
+	txt := strings.TrimSpace(c.PostForm("mix"))
+	if txt == "" {
+		return nil, nil
+	}
+	mix := []requester.Request{}
+	if err := json.Unmarshal([]byte(txt), &mix); err != nil {
+		return nil, fmt.Errorf("parsing the request mix: %s", err.Error())
+	}
+	if err := (requester.Workload{Mix: mix}).Validate(); err != nil {
+		return nil, fmt.Errorf("building the request mix: %s", err.Error())
+	}
+	return mix, nil
+}
+
+// getFeeder returns the feeder uploaded with the plan or pasted in the form, if any
+func getFeeder(c *gin.Context) (*requester.Feeder, error) {
+	f := requester.Feeder{
+		Format: c.PostForm("feeder_format"),
+		Order:  c.PostForm("feeder_order"),
+		Data:   c.PostForm("feeder_data"),
+	}
+	if file, err := c.FormFile("feeder"); err == nil {
+		r, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening the feeder: %s", err.Error())
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading the feeder: %s", err.Error())
+		}
+		f.Data = string(data)
+		if f.Format == "" && (strings.HasSuffix(file.Filename, ".jsonl") || strings.HasSuffix(file.Filename, ".ndjson")) {
+			f.Format = requester.FeederJSONL
+		}
+	}
+	if strings.TrimSpace(f.Data) == "" {
+		return nil, nil
+	}
+	if f.Format == "" {
+		f.Format = requester.FeederCSV
+	}
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("building the feeder: %s", err.Error())
+	}
+	return &f, nil
+}
+
+// getScenario returns the chained requests of the plan, if any
+func getScenario(c *gin.Context) ([]requester.ScenarioStep, error) {
+	txt := strings.TrimSpace(c.PostForm("scenario"))
+	if txt == "" {
+		return nil, nil
+	}
+	scenario := []requester.ScenarioStep{}
+	if err := json.Unmarshal([]byte(txt), &scenario); err != nil {
+		return nil, fmt.Errorf("parsing the scenario: %s", err.Error())
+	}
+	if err := (requester.Workload{Scenario: scenario}).Validate(); err != nil {
+		return nil, fmt.Errorf("building the scenario: %s", err.Error())
+	}
+	return scenario, nil
+}
+
 func parseHeaders(headersTxt string) http.Header {
 	res := http.Header{}
 	headersTxt = strings.Replace(strings.Trim(headersTxt, " "), "\r", "", -1)
@@ -290,14 +1031,218 @@ func parseHeaders(headersTxt string) http.Header {
 	return res
 }
 
+// getSLO returns the SLO of the capacity search or nil if the search is not enabled
+func getSLO(c *gin.Context) *SLO {
+	if c.PostForm("search") == "" {
+		return nil
+	}
+	return &SLO{
+		Percentile: getFloat(c, "slo_percentile", 99),
+		Latency:    time.Duration(getFloat(c, "slo_latency", 0) * float64(time.Millisecond)),
+		ErrorRatio: getFloat(c, "slo_errors", 0) / 100,
+	}
+}
+
+// getMode returns the mode of the plan, concurrency by default
+func getMode(c *gin.Context) (PlanMode, error) {
+	switch mode := PlanMode(c.PostForm("mode")); mode {
+	case "", ModeConcurrency, ModeRate:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown mode %q", mode)
+	}
+}
+
+// getEngine returns the engine running the plan, the native one by default
+func getEngine(c *gin.Context) (Engine, error) {
+	switch engine := Engine(c.PostForm("engine")); engine {
+	case "", EngineNative, EngineHey:
+		return engine, nil
+	default:
+		return "", fmt.Errorf("unknown engine %q", engine)
+	}
+}
+
+// getTags returns the comma separated tags of the plan
+func getTags(c *gin.Context) []string {
+	var res []string
+	for _, tag := range strings.Split(c.PostForm("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+// getIndexQuery returns the query of the index. The tests are sorted by
+// creation, newest first, by default.
+func getIndexQuery(c *gin.Context) (IndexQuery, error) {
+	q := IndexQuery{
+		Q:       strings.TrimSpace(c.Query("q")),
+		Name:    strings.TrimSpace(c.Query("name")),
+		Target:  strings.TrimSpace(c.Query("target")),
+		Status:  ResultStatus(c.Query("status")),
+		Verdict: c.Query("verdict"),
+		Sort:    c.Query("sort"),
+		Desc:    c.DefaultQuery("order", "desc") == "desc",
+		Page:    getQueryInt(c, "page", 1),
+		PerPage: getQueryInt(c, "per_page", defaultPageSize),
+	}
+	for _, v := range c.QueryArray("tag") {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				q.Tags = append(q.Tags, tag)
+			}
+		}
+	}
+	var err error
+	if q.From, err = parseDate(c.Query("from"), false); err != nil {
+		return q, fmt.Errorf("parsing the from param: %s", err.Error())
+	}
+	if q.To, err = parseDate(c.Query("to"), true); err != nil {
+		return q, fmt.Errorf("parsing the to param: %s", err.Error())
+	}
+	return q, nil
+}
+
+// parseDate accepts a RFC3339 time or a date. The dates are the start of the
+// day or, for the end of a range, its last instant.
+func parseDate(v string, end bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return t, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// getTolerances returns the tolerances of a baseline, given as percentages.
+// The missing ones take the default values.
+func getTolerances(c *gin.Context) Tolerances {
+	t := Tolerances{
+		RPSDrop:         getFloat(c, "rps_drop", 100*DefaultTolerances.RPSDrop) / 100,
+		Percentiles:     DefaultTolerances.Percentiles,
+		LatencyIncrease: getFloat(c, "latency_increase", 100*DefaultTolerances.LatencyIncrease) / 100,
+		ErrorIncrease:   getFloat(c, "error_increase", 100*DefaultTolerances.ErrorIncrease) / 100,
+	}
+	if c.PostForm("percentiles") != "" {
+		t.Percentiles = parsePercentiles([]string{c.PostForm("percentiles")})
+	}
+	return t
+}
+
+// getWarmup returns the warmup of the plan or nil if it is disabled
+func getWarmup(c *gin.Context) *Warmup {
+	w := Warmup{
+		Duration: time.Duration(getIntOrDefault(c, "warmup_duration", 0)) * time.Second,
+		Requests: getIntOrDefault(c, "warmup_requests", 0),
+		Load:     getIntOrDefault(c, "warmup_load", 0),
+	}
+	if !w.Enabled() {
+		return nil
+	}
+	return &w
+}
+
+// getStopConditions returns the stop conditions of the plan or nil if none is set
+func getStopConditions(c *gin.Context) *StopConditions {
+	stop := StopConditions{
+		ErrorRatio:  getFloat(c, "stop_errors", 0) / 100,
+		Status:      strings.TrimSpace(c.PostForm("stop_status")),
+		StatusShare: getFloat(c, "stop_status_share", 0) / 100,
+		Percentile:  getFloat(c, "stop_percentile", 99),
+		Latency:     time.Duration(getFloat(c, "stop_latency", 0) * float64(time.Millisecond)),
+		RPSDrop:     getFloat(c, "stop_rps_drop", 0) / 100,
+	}
+	if !stop.Enabled() {
+		return nil
+	}
+	return &stop
+}
+
+func toJSON(v interface{}) string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatHeaders(h http.Header) string {
+	lines := []string{}
+	for k, vs := range h {
+		for _, v := range vs {
+			lines = append(lines, k+": "+v)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
 func getInt(c *gin.Context, key string) int {
+	return getIntOrDefault(c, key, -1)
+}
+
+func getIntOrDefault(c *gin.Context, key string, d int) int {
 	i, err := strconv.Atoi(c.PostForm(key))
 	if err != nil {
-		return -1
+		return d
 	}
 	return i
 }
 
+func getQueryInt(c *gin.Context, key string, d int) int {
+	i, err := strconv.Atoi(c.Query(key))
+	if err != nil {
+		return d
+	}
+	return i
+}
+
+func getFloat(c *gin.Context, key string, d float64) float64 {
+	f, err := strconv.ParseFloat(c.PostForm(key), 64)
+	if err != nil {
+		return d
+	}
+	return f
+}
+
 func formatLatency(l float64) string {
 	return time.Duration(int64(l * float64(time.Second))).String()
 }
+
+// formatDate returns the date of the time, as expected by the date inputs
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(time.Local).Format("2006-01-02")
+}
+
+// pages returns the numbers of the pages, from 1 to n
+func pages(n int) []int {
+	res := make([]int, n)
+	for i := range res {
+		res[i] = i + 1
+	}
+	return res
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
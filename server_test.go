@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -74,7 +76,7 @@ func TestNewServer_dbError(t *testing.T) {
 		return []requester.Report{}, nil
 	})
 
-	s, err := NewServer(gin.New(), store, exec)
+	s, err := NewServer(gin.New(), store, NewJobRegistry(context.Background(), exec, 1), NewBroker(), false)
 	if err != nil {
 		t.Error(err)
 		return
@@ -119,7 +121,7 @@ func TestNewServer_browseAndHome(t *testing.T) {
 		return expectedResult, nil
 	})
 
-	s, err := NewServer(gin.New(), store, exec)
+	s, err := NewServer(gin.New(), store, NewJobRegistry(context.Background(), exec, 1), NewBroker(), false)
 	if err != nil {
 		t.Error(err)
 		return
@@ -210,12 +212,14 @@ func TestNewServer_createTest(t *testing.T) {
 
 	store := db.NewInMemory()
 
+	executed := make(chan struct{})
 	exec := dummyExecutor(func(_ context.Context, p Plan) ([]requester.Report, error) {
+		defer close(executed)
 		if p.Request.Method != expectedMethod {
 			t.Errorf("unexpected method: %s", p.Request.Method)
 		}
-		if p.Request.URL.String() != expectedURL {
-			t.Errorf("unexpected url: %s", p.Request.URL.String())
+		if p.Request.URL != expectedURL {
+			t.Errorf("unexpected url: %s", p.Request.URL)
 		}
 		if v := p.Request.Header.Get("Accept"); v != "application/json" {
 			t.Errorf("unexpected Accept header value: %s", v)
@@ -226,16 +230,13 @@ func TestNewServer_createTest(t *testing.T) {
 		if p.Sleep != -1*time.Second {
 			t.Errorf("unexpected sleep: %d", p.Sleep)
 		}
-		buf := &bytes.Buffer{}
-		buf.ReadFrom(p.Request.Body)
-		p.Request.Body.Close()
-		if body := buf.String(); body != expectedBody {
+		if body := p.Request.Body; body != expectedBody {
 			t.Errorf("unexpected request body: %s", body)
 		}
 		return []requester.Report{}, nil
 	})
 
-	s, err := NewServer(gin.New(), store, exec)
+	s, err := NewServer(gin.New(), store, NewJobRegistry(context.Background(), exec, 1), NewBroker(), false)
 	if err != nil {
 		t.Error(err)
 		return
@@ -262,9 +263,121 @@ func TestNewServer_createTest(t *testing.T) {
 	w := httptest.NewRecorder()
 	s.Engine.ServeHTTP(w, req)
 
-	if w.Result().StatusCode != 301 {
+	if w.Result().StatusCode != http.StatusAccepted {
 		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
 	}
+
+	job := Job{}
+	if err := json.NewDecoder(w.Result().Body).Decode(&job); err != nil {
+		t.Error(err)
+		return
+	}
+	if job.Name != expectedName {
+		t.Errorf("unexpected job name: %s", job.Name)
+	}
+	if l := w.Result().Header.Get("Location"); l != "/jobs/"+job.ID {
+		t.Errorf("unexpected location: %s", l)
+	}
+
+	select {
+	case <-executed:
+	case <-time.After(time.Second):
+		t.Error("the plan was not executed")
+	}
+}
+
+func TestNewServer_createTestInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	exec := dummyExecutor(func(_ context.Context, _ Plan) ([]requester.Report, error) {
+		return []requester.Report{}, nil
+	})
+	s, err := NewServer(gin.New(), db.NewInMemory(), NewJobRegistry(context.Background(), exec, 1), NewBroker(), false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, body := range []string{
+		"name=a&url=http://example.com&min=1&max=2&steps=2&mode=unknown",
+		"name=a&url=http://example.com&min=1&max=2&steps=2&mode=Rate",
+		"name=a&url=http://example.com&min=1&max=2&steps=2&engine=wrk",
+		"name=a/b&url=http://example.com&min=1&max=2&steps=2",
+		"name=..&url=http://example.com&min=1&max=2&steps=2",
+		"name=a&url=http://example.com&min=1&steps=2&search=on&slo_latency=100",
+		"name=a&url=http://example.com&min=10&max=2&steps=2&search=on&slo_latency=100",
+	} {
+		req, _ := http.NewRequest("POST", "/test", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.Engine.ServeHTTP(w, req)
+		if w.Result().StatusCode != http.StatusBadRequest {
+			t.Errorf("unexpected status code for %s: %d", body, w.Result().StatusCode)
+		}
+	}
+	if jobs := s.Jobs.List(); len(jobs) != 0 {
+		t.Errorf("unexpected jobs: %+v", jobs)
+	}
+}
+
+func TestNewServer_jobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := db.NewInMemory()
+	exec := dummyExecutor(func(_ context.Context, _ Plan) ([]requester.Report, error) {
+		return []requester.Report{}, errors.New("you should expect me")
+	})
+	jobs := NewJobRegistry(context.Background(), exec, 1)
+
+	s, err := NewServer(gin.New(), store, jobs, NewBroker(), false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	job := waitForJob(t, jobs, jobs.Submit(Plan{Name: "some-name"}).ID)
+	if job.Status != JobFailed {
+		t.Errorf("unexpected status: %s", job.Status)
+	}
+
+	req, _ := http.NewRequest("GET", "/jobs/"+job.ID, nil)
+	w := httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+	res := Job{}
+	if err := json.NewDecoder(w.Result().Body).Decode(&res); err != nil {
+		t.Error(err)
+		return
+	}
+	if res.Error != "you should expect me" {
+		t.Errorf("unexpected error: %s", res.Error)
+	}
+
+	req, _ = http.NewRequest("GET", "/jobs/unknown", nil)
+	w = httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+}
+
+func waitForJob(t *testing.T, jobs JobRegistry, id string) Job {
+	for i := 0; i < 100; i++ {
+		job, err := jobs.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status.Done() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s not finished in time", id)
+	return Job{}
 }
 
 type dummyExecutor func(ctx context.Context, plan Plan) ([]requester.Report, error)
@@ -288,6 +401,22 @@ func (e erroredStore) Set(key string, r io.Reader) (int, error) {
 	return -1, e.Error
 }
 
+func (e erroredStore) Delete(key string) error {
+	return e.Error
+}
+
+func (e erroredStore) Rename(from, to string) error {
+	return e.Error
+}
+
+func (e erroredStore) Meta(key string) (db.Metadata, error) {
+	return db.Metadata{}, e.Error
+}
+
+func (e erroredStore) SetMeta(key string, m db.Metadata) error {
+	return e.Error
+}
+
 // func Test_getRequest(t *testing.T) {
 
 // 	headers := parseHeaders(`Accept: application/json
@@ -303,3 +432,471 @@ func (e erroredStore) Set(key string, r io.Reader) (int, error) {
 // 		t.Errorf("unexpected Content-Type header: %s", v)
 // 	}
 // }
+
+func TestNewServer_jobEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := db.NewInMemory()
+	events := NewBroker()
+	exec := &executor{
+		DB: store,
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				return bytes.NewBufferString(`{"NumRes":1,"Rps":42}`)
+			})
+		},
+		Events: events,
+	}
+	jobs := NewJobRegistry(context.Background(), exec, 1)
+
+	s, err := NewServer(gin.New(), store, jobs, events, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	job := waitForJob(t, jobs, jobs.Submit(Plan{Name: "some-name", Min: 1, Max: 3, Steps: 1, Request: requester.Request{URL: "/"}}).ID)
+	if job.Status != JobSucceeded {
+		t.Errorf("unexpected status: %s", job.Status)
+	}
+
+	// the steps are numbered from 1
+	history, _, _ := events.Subscribe(job.ID)
+	steps := []int{}
+	for _, e := range history {
+		steps = append(steps, e.Step)
+	}
+	if fmt.Sprint(steps) != "[0 1 1 2 2 2]" {
+		t.Errorf("unexpected steps: %v", steps)
+	}
+
+	srv := httptest.NewServer(s.Engine)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/jobs/" + job.ID + "/events")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+
+	received := []string{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "event:") {
+			received = append(received, strings.TrimPrefix(line, "event:"))
+		}
+	}
+	expected := []string{"plan_start", "step_start", "step_end", "step_start", "step_end", "plan_end"}
+	if strings.Join(received, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected events: %v", received)
+	}
+
+	resp, err = http.Get(srv.URL + "/jobs/unknown/events")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+func TestNewServer_rerunAndClone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	plan := Plan{
+		Name:     "stored",
+		Min:      2,
+		Max:      20,
+		Steps:    3,
+		Duration: 7 * time.Second,
+		Request: requester.Request{
+			Method: "POST",
+			URL:    "http://some.example.com/endpoint",
+			Header: http.Header{"Content-Type": []string{"application/json"}},
+			Body:   `{"a":"b"}`,
+		},
+	}
+
+	store := db.NewInMemory()
+	data, _ := encodeResult(Result{Plan: &plan, Status: ResultCompleted, Reports: []requester.Report{}})
+	store.Set("stored", data)
+	store.Set("legacy", bytes.NewBufferString("[]"))
+
+	executed := make(chan Plan, 1)
+	exec := dummyExecutor(func(_ context.Context, p Plan) ([]requester.Report, error) {
+		executed <- p
+		return []requester.Report{}, nil
+	})
+
+	s, err := NewServer(gin.New(), store, NewJobRegistry(context.Background(), exec, 1), NewBroker(), false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	req, _ := http.NewRequest("GET", "/?from=stored", nil)
+	w := httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+	body := w.Body.String()
+	for _, expected := range []string{
+		`value="http://some.example.com/endpoint"`,
+		`<option selected>POST</option>`,
+		`value="20"`,
+		`value="7"`,
+		`Content-Type: application/json`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("%s not present in the response body", expected)
+		}
+	}
+
+	for url, status := range map[string]int{
+		"/browse/legacy/rerun":  http.StatusConflict,
+		"/browse/unknown/rerun": http.StatusNotFound,
+		"/browse/stored/rerun":  http.StatusAccepted,
+	} {
+		req, _ := http.NewRequest("POST", url, nil)
+		w := httptest.NewRecorder()
+		s.Engine.ServeHTTP(w, req)
+
+		if w.Result().StatusCode != status {
+			t.Errorf("unexpected status code for %s: %d", url, w.Result().StatusCode)
+		}
+	}
+
+	select {
+	case p := <-executed:
+		if p.Name != plan.Name || p.Max != plan.Max || p.Request.Body != plan.Request.Body {
+			t.Errorf("unexpected plan: %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Error("the plan was not executed")
+	}
+}
+
+func TestNewServer_samples(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	reports := []requester.Report{
+		{C: 1, Start: start, Samples: []requester.Sample{
+			{Offset: 0.5, Latency: 0.25, Status: 200, Bytes: 12},
+		}},
+		{C: 2, Start: start.Add(time.Second), Samples: []requester.Sample{
+			{Name: "login", Offset: 0, Latency: 1.5, Error: "timeout"},
+		}},
+	}
+	store := db.NewInMemory()
+	data, _ := encodeResult(Result{Status: ResultCompleted, Reports: reports})
+	store.Set("stored", data)
+
+	exec := dummyExecutor(func(_ context.Context, _ Plan) ([]requester.Report, error) {
+		return []requester.Report{}, nil
+	})
+	s, err := NewServer(gin.New(), store, NewJobRegistry(context.Background(), exec, 1), NewBroker(), false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	req, _ := http.NewRequest("GET", "/download/unknown/samples", nil)
+	w := httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+
+	req, _ = http.NewRequest("GET", "/download/stored/samples", nil)
+	w = httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+		return
+	}
+	if ct := w.Result().Header.Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	expected := "step,c,rate,name,start,offset,latency,conn,dns,req,delay,res,status,bytes,error\n" +
+		"0,1,0,,2020-01-02T03:04:05.5Z,0.5,0.25,0,0,0,0,0,200,12,\n" +
+		"1,2,0,login,2020-01-02T03:04:06Z,0,1.5,0,0,0,0,0,0,0,timeout\n"
+	if body := w.Body.String(); body != expected {
+		t.Errorf("unexpected body:\n%s", body)
+	}
+}
+
+func TestNewServer_compare(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := db.NewInMemory()
+	for id, rps := range map[string]float64{"baseline": 100, "candidate": 120} {
+		r := requester.Report{C: 1}
+		r.Rps = rps
+		data, _ := encodeResult(Result{Status: ResultCompleted, Reports: []requester.Report{r}})
+		store.Set(id, data)
+	}
+	data, _ := encodeResult(Result{Status: ResultCompleted, Reports: []requester.Report{{Rate: 10}}})
+	store.Set("rate", data)
+
+	exec := dummyExecutor(func(_ context.Context, _ Plan) ([]requester.Report, error) {
+		return []requester.Report{}, nil
+	})
+	s, err := NewServer(gin.New(), store, NewJobRegistry(context.Background(), exec, 1), NewBroker(), false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for url, status := range map[string]int{
+		"/compare?id=baseline":              http.StatusBadRequest,
+		"/compare?id=baseline&id=unknown":   http.StatusNotFound,
+		"/compare?id=baseline&id=rate":      http.StatusBadRequest,
+		"/compare?id=baseline,candidate":    http.StatusOK,
+		"/compare?id=baseline&id=candidate": http.StatusOK,
+	} {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		s.Engine.ServeHTTP(w, req)
+		if w.Result().StatusCode != status {
+			t.Errorf("unexpected status code for %s: %d", url, w.Result().StatusCode)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/compare?id=baseline&id=candidate", nil)
+	w := httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+	cmp := Comparison{}
+	if err := json.NewDecoder(w.Body).Decode(&cmp); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(cmp.Steps) != 1 || cmp.Steps[0].Results[1].Deltas.Rps.Change != 20 {
+		t.Errorf("unexpected comparison: %+v", cmp)
+	}
+
+	req, _ = http.NewRequest("GET", "/compare?id=baseline&id=candidate", nil)
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK || !strings.Contains(w.Body.String(), `<small class="text-success">&#43;20.0%</small>`) {
+		t.Errorf("unexpected html: %d", w.Result().StatusCode)
+	}
+}
+
+func TestNewServer_baselines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := db.NewInMemory()
+	r := requester.Report{C: 1}
+	r.Rps = 100
+	data, _ := encodeResult(Result{Plan: &Plan{Name: "svc"}, Status: ResultCompleted, Reports: []requester.Report{r}})
+	store.Set("svc", data)
+
+	exec := dummyExecutor(func(_ context.Context, _ Plan) ([]requester.Report, error) {
+		return []requester.Report{}, nil
+	})
+	s, err := NewServer(gin.New(), store, NewJobRegistry(context.Background(), exec, 1), NewBroker(), false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, tc := range []struct {
+		method, url, body string
+		status            int
+	}{
+		{"POST", "/browse/unknown/baseline", "", http.StatusNotFound},
+		{"POST", "/browse/svc/baseline", "rps_drop=5", http.StatusCreated},
+		{"POST", "/browse/svc/baseline", "tag=nightly", http.StatusCreated},
+		{"DELETE", "/baselines/tag:unknown", "", http.StatusNotFound},
+		{"DELETE", "/baselines/tag:nightly", "", http.StatusNoContent},
+		{"POST", "/test", "name=_baselines&url=http://example.com", http.StatusBadRequest},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.Engine.ServeHTTP(w, req)
+		if w.Result().StatusCode != tc.status {
+			t.Errorf("unexpected status code for %s %s: %d", tc.method, tc.url, w.Result().StatusCode)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/baselines", nil)
+	w := httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+	baselines := map[string]Baseline{}
+	if err := json.NewDecoder(w.Body).Decode(&baselines); err != nil {
+		t.Error(err)
+		return
+	}
+	b, ok := baselines["name:svc"]
+	if len(baselines) != 1 || !ok || b.ID != "svc" || b.Tolerances.RPSDrop != 0.05 || len(b.Steps) != 1 {
+		t.Errorf("unexpected baselines: %+v", baselines)
+	}
+
+	// the baselines are not listed as tests
+	req, _ = http.NewRequest("GET", "/", nil)
+	w = httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), baselinesKey) {
+		t.Error("the baselines should not be listed")
+	}
+}
+
+func TestNewServer_manage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := db.NewInMemory()
+	for _, name := range []string{"a", "b"} {
+		data, _ := encodeResult(Result{Plan: &Plan{Name: name}, Status: ResultCompleted, Reports: []requester.Report{{C: 1}}})
+		store.Set(name, data)
+	}
+
+	exec := dummyExecutor(func(_ context.Context, _ Plan) ([]requester.Report, error) {
+		return []requester.Report{}, nil
+	})
+	s, err := NewServer(gin.New(), store, NewJobRegistry(context.Background(), exec, 1), NewBroker(), false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, tc := range []struct {
+		method, url, body string
+		status            int
+	}{
+		{"GET", "/browse/a", "", http.StatusOK},
+		{"POST", "/browse/a/meta", "tags=x,+y&notes=slow+backend", http.StatusOK},
+		{"GET", "/browse/unknown/meta", "", http.StatusNotFound},
+		{"POST", "/browse/a/rename", "name=", http.StatusBadRequest},
+		{"POST", "/browse/a/rename", "name=../c", http.StatusBadRequest},
+		{"POST", "/browse/a/rename", "name=_c", http.StatusBadRequest},
+		{"POST", "/browse/a/rename", "name=b", http.StatusConflict},
+		{"POST", "/browse/unknown/rename", "name=c", http.StatusNotFound},
+		{"POST", "/browse/a/rename", "name=c", http.StatusNoContent},
+		{"GET", "/browse/a", "", http.StatusNotFound},
+		{"DELETE", "/browse/b", "", http.StatusNoContent},
+		{"DELETE", "/browse/b", "", http.StatusNotFound},
+		{"DELETE", "/browse/_baselines", "", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.Engine.ServeHTTP(w, req)
+		if w.Result().StatusCode != tc.status {
+			t.Errorf("unexpected status code for %s %s: %d", tc.method, tc.url, w.Result().StatusCode)
+		}
+	}
+
+	keys, _ := s.keys()
+	if len(keys) != 1 || keys[0] != "c" {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+
+	req, _ := http.NewRequest("GET", "/browse/c/meta", nil)
+	w := httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+	m := db.Metadata{}
+	if err := json.NewDecoder(w.Body).Decode(&m); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(m.Tags) != 2 || m.Tags[0] != "x" || m.Tags[1] != "y" || m.Notes != "slow backend" {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+
+	// the index follows the renamed test and its metadata
+	req, _ = http.NewRequest("GET", "/tests?tag=y", nil)
+	w = httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+	page := IndexPage{}
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Error(err)
+		return
+	}
+	if page.Total != 1 || page.Tests[0].ID != "c" || page.Tests[0].Notes != "slow backend" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+
+	req, _ = http.NewRequest("GET", "/tests", nil)
+	req.Header.Set("Accept", gin.MIMEHTML)
+	w = httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+	if w.Result().StatusCode != 200 || !strings.Contains(w.Body.String(), `href="/browse/c"`) {
+		t.Errorf("unexpected response: %d %s", w.Result().StatusCode, w.Body.String())
+	}
+}
+
+func TestNewServer_search(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := db.NewInMemory()
+	for i, plan := range []Plan{
+		{Name: "users-v1", Request: requester.Request{URL: "http://api.example.com/users"}, Tags: []string{"nightly"}},
+		{Name: "users-v2", Request: requester.Request{URL: "http://api.example.com/users"}},
+		{Name: "home", Request: requester.Request{URL: "http://www.example.com/"}, Tags: []string{"nightly"}},
+	} {
+		res := Result{Plan: &plan, Status: ResultCompleted, Created: time.Date(2020, 1, 1+i, 12, 0, 0, 0, time.Local)}
+		data, _ := encodeResult(res)
+		store.Set(plan.Name, data)
+		if err := indexTest(store, plan.Name, res); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	exec := dummyExecutor(func(_ context.Context, _ Plan) ([]requester.Report, error) {
+		return []requester.Report{}, nil
+	})
+	s, err := NewServer(gin.New(), store, NewJobRegistry(context.Background(), exec, 1), NewBroker(), false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, tc := range []struct {
+		query string
+		ids   []string
+	}{
+		{"q=USERS", []string{"users-v2", "users-v1"}},
+		{"q=www", []string{"home"}},
+		{"name=v1", []string{"users-v1"}},
+		{"target=api.example.com/users&tag=nightly", []string{"users-v1"}},
+		{"from=2020-01-02&to=2020-01-02", []string{"users-v2"}},
+		{"to=2020-01-02&sort=name&order=asc", []string{"users-v1", "users-v2"}},
+		{"per_page=1&page=3", []string{"users-v1"}},
+	} {
+		req, _ := http.NewRequest("GET", "/tests?"+tc.query, nil)
+		w := httptest.NewRecorder()
+		s.Engine.ServeHTTP(w, req)
+		page := IndexPage{}
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Error(err)
+			continue
+		}
+		ids := []string{}
+		for _, test := range page.Tests {
+			ids = append(ids, test.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tc.ids, ",") {
+			t.Errorf("unexpected results for %s: %v", tc.query, ids)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/tests?from=yesterday", nil)
+	w := httptest.NewRecorder()
+	s.Engine.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+// StopConditions defines when a ramp must be stopped because the target is
+// already saturated. They are evaluated after every step and zero values
+// disable the related condition.
+type StopConditions struct {
+	// ErrorRatio is the max share of failed requests (errors and 5xx)
+	ErrorRatio float64 `json:",omitempty"`
+	// Status is a status code (e.g. 503) or class (e.g. 5xx) and StatusShare
+	// the max share of the responses allowed to get it
+	Status      string  `json:",omitempty"`
+	StatusShare float64 `json:",omitempty"`
+	// Latency is the max value allowed for the latency Percentile
+	Percentile float64       `json:",omitempty"`
+	Latency    time.Duration `json:",omitempty"`
+	// RPSDrop is the max throughput loss allowed against the previous step
+	// (e.g. 0.2 for a 20% drop)
+	RPSDrop float64 `json:",omitempty"`
+}
+
+func (s StopConditions) Enabled() bool {
+	return s.ErrorRatio > 0 || (s.Status != "" && s.StatusShare > 0) || s.Latency > 0 || s.RPSDrop > 0
+}
+
+// Check returns the reason to stop the plan after the given step or an empty
+// string if the plan can continue. The previous report is nil for the first step.
+func (s StopConditions) Check(prev *requester.Report, r requester.Report) string {
+	if s.ErrorRatio > 0 {
+		if ratio := r.ErrorRatio(); ratio > s.ErrorRatio {
+			return fmt.Sprintf("error ratio %.4f above %g", ratio, s.ErrorRatio)
+		}
+	}
+	if s.Status != "" && s.StatusShare > 0 && r.NumRes > 0 {
+		n := 0
+		for code, count := range r.StatusCodeDist {
+			if matchStatus(s.Status, code) {
+				n += count
+			}
+		}
+		if share := float64(n) / float64(r.NumRes); share > s.StatusShare {
+			return fmt.Sprintf("share of %s responses %.4f above %g", s.Status, share, s.StatusShare)
+		}
+	}
+	if s.Latency > 0 && r.HasLatencies() {
+		if l := time.Duration(r.Percentile(s.Percentile) * float64(time.Second)); l > s.Latency {
+			return fmt.Sprintf("p%g latency %s above %s", s.Percentile, l, s.Latency)
+		}
+	}
+	if s.RPSDrop > 0 && prev != nil && prev.Rps > 0 {
+		if drop := 1 - r.Rps/prev.Rps; drop > s.RPSDrop {
+			return fmt.Sprintf("throughput dropped %.1f%% from %.3f to %.3f rps", drop*100, prev.Rps, r.Rps)
+		}
+	}
+	return ""
+}
+
+// matchStatus checks if the code matches the pattern, a status code (503) or
+// a status class (5xx)
+func matchStatus(pattern string, code int) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if strings.HasSuffix(pattern, "xx") {
+		class, err := strconv.Atoi(strings.TrimSuffix(pattern, "xx"))
+		return err == nil && code/100 == class
+	}
+	expected, err := strconv.Atoi(pattern)
+	return err == nil && code == expected
+}
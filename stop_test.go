@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kpacha/load-test/db"
+	"github.com/kpacha/load-test/requester"
+	hey "github.com/rakyll/hey/requester"
+)
+
+func Test_executor_Run_stopConditions(t *testing.T) {
+	store := db.NewInMemory()
+	exec := executor{
+		DB: store,
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				if c >= 4 {
+					return bytes.NewBufferString(`{"NumRes":10,"Lats":[0.1],"StatusCodeDist":{"200":5,"503":5}}`)
+				}
+				return bytes.NewBufferString(`{"NumRes":10,"Lats":[0.1],"StatusCodeDist":{"200":10}}`)
+			})
+		},
+	}
+	p := Plan{
+		Name:     "some-name",
+		Min:      1,
+		Max:      10,
+		Steps:    1,
+		Duration: 1,
+		Request:  requester.Request{Method: "GET", URL: "/"},
+		Stop:     &StopConditions{Status: "5xx", StatusShare: 0.1},
+	}
+
+	reports, err := exec.Run(context.Background(), p)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(reports) != 4 {
+		t.Errorf("unexpected number of reports: %d", len(reports))
+	}
+
+	r, err := store.Get(p.Name)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res, err := decodeResult(r)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if res.Status != ResultStopped {
+		t.Errorf("unexpected status: %s", res.Status)
+	}
+	if res.StopReason != "step #4 (C=4): share of 5xx responses 0.5000 above 0.1" {
+		t.Errorf("unexpected stop reason: %s", res.StopReason)
+	}
+
+	// the steps are numbered by their position, not by their load
+	p.Min, p.Max, p.Steps = 2, 20, 2
+	if _, err := exec.Run(context.Background(), p); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	r, _ = store.Get(p.Name)
+	if res, _ = decodeResult(r); res.StopReason != "step #2 (C=4): share of 5xx responses 0.5000 above 0.1" {
+		t.Errorf("unexpected stop reason: %s", res.StopReason)
+	}
+}
+
+func TestStopConditions_Check(t *testing.T) {
+	for _, tc := range []struct {
+		stop   StopConditions
+		prev   *hey.Report
+		report hey.Report
+		reason string
+	}{
+		{
+			stop:   StopConditions{ErrorRatio: 0.1, Status: "503", StatusShare: 0.1, Percentile: 50, Latency: time.Second, RPSDrop: 0.5},
+			prev:   &hey.Report{Rps: 100},
+			report: hey.Report{NumRes: 10, Rps: 60, Lats: []float64{0.1}, StatusCodeDist: map[int]int{200: 10}},
+		},
+		{
+			stop:   StopConditions{ErrorRatio: 0.1},
+			report: hey.Report{NumRes: 4, ErrorDist: map[string]int{"timeout": 1}},
+			reason: "error ratio 0.2500 above 0.1",
+		},
+		{
+			stop:   StopConditions{Status: "429", StatusShare: 0.2},
+			report: hey.Report{NumRes: 4, StatusCodeDist: map[int]int{200: 2, 429: 2}},
+			reason: "share of 429 responses 0.5000 above 0.2",
+		},
+		{
+			stop:   StopConditions{Percentile: 50, Latency: 100 * time.Millisecond},
+			report: hey.Report{NumRes: 3, Lats: []float64{0.01, 0.2, 0.2}},
+			reason: "p50 latency 200ms above 100ms",
+		},
+		{
+			stop:   StopConditions{RPSDrop: 0.2},
+			prev:   &hey.Report{Rps: 100},
+			report: hey.Report{Rps: 50},
+			reason: "throughput dropped 50.0% from 100.000 to 50.000 rps",
+		},
+		{
+			stop:   StopConditions{RPSDrop: 0.2},
+			report: hey.Report{Rps: 50},
+		},
+	} {
+		var prev *requester.Report
+		if tc.prev != nil {
+			prev = &requester.Report{Report: *tc.prev}
+		}
+		if reason := tc.stop.Check(prev, requester.Report{Report: tc.report}); reason != tc.reason {
+			t.Errorf("unexpected reason. have %q want %q", reason, tc.reason)
+		}
+	}
+}
+
+func Test_executor_Run_nothingSent(t *testing.T) {
+	store := db.NewInMemory()
+	exec := executor{
+		DB: store,
+		RequesterFactory: func(req *http.Request, _ time.Duration) requester.Requester {
+			return dummyRequester(func(ctx context.Context, c int) io.Reader {
+				if c >= 3 {
+					return bytes.NewBufferString(`{}`)
+				}
+				return bytes.NewBufferString(`{"NumRes":10}`)
+			})
+		},
+	}
+	p := Plan{Name: "some-name", Min: 1, Max: 10, Steps: 1, Duration: 1, Request: requester.Request{Method: "GET", URL: "/"}}
+
+	reports, err := exec.Run(context.Background(), p)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(reports) != 2 {
+		t.Errorf("unexpected number of reports: %d", len(reports))
+	}
+	r, _ := store.Get(p.Name)
+	if res, _ := decodeResult(r); res.Status != ResultStopped || res.StopReason != "step #3 (C=3): no requests sent" {
+		t.Errorf("unexpected result: %s %s", res.Status, res.StopReason)
+	}
+
+	// a plan not sending anything fails
+	p.Min = 3
+	if _, err := exec.Run(context.Background(), p); err == nil || err.Error() != "executing the plan: executing the step #1 of the plan: no requests sent" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "github.com/kpacha/load-test/requester"
+
+// TimelinePoint is a bucket of the time series of a step, placed in the
+// timeline of the whole plan
+type TimelinePoint struct {
+	// Time is the start of the bucket in seconds, relative to the start of the
+	// first step
+	Time float64
+	Step int
+	requester.Bucket
+}
+
+// timeline joins the time series of all the steps. The sleeps between the
+// steps are kept as gaps.
+func timeline(reports []requester.Report) []TimelinePoint {
+	res := []TimelinePoint{}
+	var first *requester.Report
+	for i, r := range reports {
+		if len(r.TimeSeries) == 0 || r.Start.IsZero() {
+			continue
+		}
+		if first == nil {
+			first = &reports[i]
+		}
+		offset := r.Start.Sub(first.Start).Seconds()
+		for _, b := range r.TimeSeries {
+			res = append(res, TimelinePoint{Time: offset + b.Offset, Step: i, Bucket: b})
+		}
+	}
+	return res
+}
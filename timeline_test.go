@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+func Test_timeline(t *testing.T) {
+	start := time.Now()
+	reports := []requester.Report{
+		{C: 1, Start: start, TimeSeries: []requester.Bucket{{Offset: 0, Rps: 10}, {Offset: 1, Rps: 12}}},
+		// reports of the hey engine have no time series
+		{C: 2},
+		{C: 3, Start: start.Add(5 * time.Second), TimeSeries: []requester.Bucket{{Offset: 0, Rps: 30}}},
+	}
+
+	points := timeline(reports)
+	if len(points) != 3 {
+		t.Errorf("unexpected timeline: %+v", points)
+		return
+	}
+	for i, expected := range []TimelinePoint{
+		{Time: 0, Step: 0, Bucket: requester.Bucket{Offset: 0, Rps: 10}},
+		{Time: 1, Step: 0, Bucket: requester.Bucket{Offset: 1, Rps: 12}},
+		{Time: 5, Step: 2, Bucket: requester.Bucket{Offset: 0, Rps: 30}},
+	} {
+		if points[i] != expected {
+			t.Errorf("unexpected point #%d: %+v", i, points[i])
+		}
+	}
+
+	if points := timeline([]requester.Report{{C: 1}}); len(points) != 0 {
+		t.Errorf("unexpected timeline: %+v", points)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/kpacha/load-test/requester"
+)
+
+// Warmup defines the load sent before the first measured step, so cold caches,
+// JIT compilers and TLS handshakes do not pollute the results. It ends after
+// the Duration or the number of Requests, whatever happens first.
+type Warmup struct {
+	Duration time.Duration `json:",omitempty"`
+	Requests int           `json:",omitempty"`
+	// Load is the concurrency or rate of the warmup. It defaults to the load of
+	// the first step
+	Load int `json:",omitempty"`
+}
+
+func (w Warmup) Enabled() bool {
+	return w.Duration > 0 || w.Requests > 0
+}
+
+func (w Warmup) String() string {
+	switch {
+	case w.Duration > 0 && w.Requests > 0:
+		return fmt.Sprintf("%s or %d requests", w.Duration, w.Requests)
+	case w.Requests > 0:
+		return fmt.Sprintf("%d requests", w.Requests)
+	}
+	return w.Duration.String()
+}
+
+// warmup runs the warmup of the plan and stores its report apart from the
+// ones of the measured steps
+func (e *executor) warmup(ctx context.Context, plan Plan, requestr requester.Requester, load int, res *Result) error {
+	w := plan.Warmup
+	if w.Load > 0 {
+		load = w.Load
+	}
+	log.Printf("warming up with load %d for %s ...\n", load, w.String())
+
+	localCtx, localCancel := ctx, func() {}
+	if w.Duration > 0 {
+		localCtx, localCancel = context.WithTimeout(ctx, w.Duration)
+	}
+
+	var r io.Reader
+	if bounded, ok := requestr.(requester.BoundedRequester); ok && w.Requests > 0 {
+		r = bounded.RunN(localCtx, load, w.Requests)
+	} else {
+		r = requestr.Run(localCtx, load)
+	}
+	localCancel()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("warming up: %s", err.Error())
+	}
+
+	report := requester.Report{}
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return fmt.Errorf("decoding the warmup results: %s", err.Error())
+	}
+	if plan.Mode == ModeRate {
+		report.Rate = load
+	} else {
+		report.C = load
+	}
+	report.URL = plan.Request.URL
+	res.Warmup = &report
+
+	return nil
+}
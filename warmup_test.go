@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kpacha/load-test/db"
+	"github.com/kpacha/load-test/requester"
+)
+
+type boundedDummyRequester struct {
+	dummyRequester
+	runN func(ctx context.Context, c, n int) io.Reader
+}
+
+func (d boundedDummyRequester) RunN(ctx context.Context, c, n int) io.Reader {
+	return d.runN(ctx, c, n)
+}
+
+func Test_executor_Run_warmup(t *testing.T) {
+	store := db.NewInMemory()
+	exec := executor{
+		DB: store,
+		RequesterFactory: func(req *http.Request, timeout time.Duration) requester.Requester {
+			if timeout != time.Minute {
+				t.Errorf("unexpected timeout: %s", timeout)
+			}
+			return boundedDummyRequester{
+				dummyRequester: func(ctx context.Context, c int) io.Reader {
+					return bytes.NewBufferString(`{"NumRes":10}`)
+				},
+				runN: func(ctx context.Context, c, n int) io.Reader {
+					if c != 3 || n != 50 {
+						t.Errorf("unexpected warmup. c: %d, n: %d", c, n)
+					}
+					if _, ok := ctx.Deadline(); !ok {
+						t.Error("the warmup should have a deadline")
+					}
+					return bytes.NewBufferString(`{"NumRes":50}`)
+				},
+			}
+		},
+	}
+	p := Plan{
+		Name:     "some-name",
+		Min:      3,
+		Max:      5,
+		Steps:    1,
+		Duration: time.Millisecond,
+		Request:  requester.Request{Method: "GET", URL: "/"},
+		Warmup:   &Warmup{Duration: time.Minute, Requests: 50},
+	}
+
+	reports, err := exec.Run(context.Background(), p)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(reports) != 2 {
+		t.Errorf("unexpected number of reports: %d", len(reports))
+	}
+
+	r, err := store.Get(p.Name)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res, err := decodeResult(r)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if res.Warmup == nil {
+		t.Error("the warmup was not stored")
+		return
+	}
+	if res.Warmup.NumRes != 50 || res.Warmup.C != 3 {
+		t.Errorf("unexpected warmup report: %+v", res.Warmup)
+	}
+	for _, report := range res.Reports {
+		if report.NumRes != 10 {
+			t.Errorf("unexpected step report: %+v", report)
+		}
+	}
+}